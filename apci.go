@@ -23,6 +23,45 @@ const (
 	uFrame byte = 3
 )
 
+//FrameType 帧格式,I帧、S帧或U帧
+type FrameType byte
+
+const (
+	//FrameI 编号的信息传输格式
+	FrameI = FrameType(iFrame)
+	//FrameS 编号的监视功能格式
+	FrameS = FrameType(sFrame)
+	//FrameU 未编号的控制功能格式
+	FrameU = FrameType(uFrame)
+)
+
+//String 帧格式名称
+func (t FrameType) String() string {
+	switch t {
+	case FrameI:
+		return "I"
+	case FrameS:
+		return "S"
+	case FrameU:
+		return "U"
+	}
+	return fmt.Sprintf("FrameType(%d)", byte(t))
+}
+
+//DecodeControlField 解析4个字节的控制域,I帧返回发送序号和接收序号,S帧返回接收序号,
+//U帧返回第一个字节的功能位(如启动激活0x07),无需解析整个APDU
+func DecodeControlField(b [4]byte) (frameType FrameType, ssn uint16, rsn uint16, uCmd byte) {
+	apci := &APCI{Ctr1: b[0], Ctr2: b[1], Ctr3: b[2], Ctr4: b[3]}
+	switch t, f, _ := apci.ParseCtr(); f := f.(type) {
+	case IFrame:
+		return FrameType(t), uint16(f.Send), uint16(f.Recv), 0
+	case SFrame:
+		return FrameType(t), 0, uint16(f.Recv), 0
+	default:
+		return FrameU, 0, 0, b[0]
+	}
+}
+
 //APCI ..
 type APCI struct {
 	ApduLen int
@@ -73,6 +112,38 @@ func parseLittleEndianUInt16(i uint16) []byte {
 	return bytes
 }
 
+//decodeIOA 解析3个字节的信息对象地址,BigEndianAddr为true时按大端解析
+func decodeIOA(b []byte) uint32 {
+	if BigEndianAddr {
+		return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+	}
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+//encodeIOA 编码3个字节的信息对象地址,BigEndianAddr为true时按大端编码
+func encodeIOA(ioa uint32) []byte {
+	if BigEndianAddr {
+		return []byte{byte(ioa >> 16), byte(ioa >> 8), byte(ioa)}
+	}
+	return []byte{byte(ioa), byte(ioa >> 8), byte(ioa >> 16)}
+}
+
+//decodeCommonAddr 解析2个字节的公共地址,BigEndianAddr为true时按大端解析
+func decodeCommonAddr(b []byte) uint16 {
+	if BigEndianAddr {
+		return binary.BigEndian.Uint16(b)
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+//encodeCommonAddr 编码2个字节的公共地址,BigEndianAddr为true时按大端编码
+func encodeCommonAddr(commonAddr uint16) []byte {
+	if BigEndianAddr {
+		return parseBigEndianUInt16(commonAddr)
+	}
+	return parseLittleEndianUInt16(commonAddr)
+}
+
 //convertBytes 转换发送数据
 func convertBytes(data []byte) []byte {
 	sendData := make([]byte, 0, 0)
@@ -83,6 +154,28 @@ func convertBytes(data []byte) []byte {
 	return sendData
 }
 
+//encodeUFrame 编码U帧
+func encodeUFrame(cmd [4]byte) []byte {
+	return convertBytes(convert4BytesToSlice(cmd))
+}
+
+//encodeSFrame 编码接收序号为rsn的S帧
+func encodeSFrame(rsn int16) []byte {
+	data := make([]byte, 0, 4)
+	data = append(data, 0x01, 0x00)
+	data = append(data, parseLittleEndianUInt16(uint16(rsn<<1))...)
+	return convertBytes(data)
+}
+
+//encodeIFrame 编码发送序号为ssn、接收序号为rsn的I帧
+func encodeIFrame(ssn, rsn int16, asdu []byte) []byte {
+	data := make([]byte, 0, 4+len(asdu))
+	data = append(data, parseLittleEndianUInt16(uint16(ssn<<1))...)
+	data = append(data, parseLittleEndianUInt16(uint16(rsn<<1))...)
+	data = append(data, asdu...)
+	return convertBytes(data)
+}
+
 //ParseCtr 解析控制域
 func (apci *APCI) ParseCtr() (byte, interface{}, error) {
 	switch {
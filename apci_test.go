@@ -0,0 +1,115 @@
+package iec104
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDecodeControlField(t *testing.T) {
+	tests := []struct {
+		name          string
+		b             [4]byte
+		wantFrameType FrameType
+		wantSsn       uint16
+		wantRsn       uint16
+		wantUCmd      byte
+	}{
+		{"测试I帧", [4]byte{0x02, 0x01, 0x06, 0x00}, FrameI, 129, 3, 0},
+		{"测试S帧", [4]byte{0x01, 0x00, 0xFE, 0xFF}, FrameS, 0, 32767, 0},
+		{"测试启动激活帧", startDtAct, FrameU, 0, 0, 0x07},
+		{"测试测试确认帧", testFrCon, FrameU, 0, 0, 0x83},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotFrameType, gotSsn, gotRsn, gotUCmd := DecodeControlField(tt.b)
+			if gotFrameType != tt.wantFrameType || gotSsn != tt.wantSsn || gotRsn != tt.wantRsn || gotUCmd != tt.wantUCmd {
+				t.Errorf("DecodeControlField() = %v, %d, %d, %#x, want %v, %d, %d, %#x",
+					gotFrameType, gotSsn, gotRsn, gotUCmd, tt.wantFrameType, tt.wantSsn, tt.wantRsn, tt.wantUCmd)
+			}
+		})
+	}
+}
+
+func TestBigEndianAddr(t *testing.T) {
+	tests := []struct {
+		name           string
+		bigEndian      bool
+		wantCommonAddr uint16
+		wantIOA        uint32
+	}{
+		{"测试小端序", false, 0x0201, 0x050403},
+		{"测试大端序", true, 0x0102, 0x030405},
+	}
+	asduBytes := []byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x01}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			BigEndianAddr = tt.bigEndian
+			defer func() { BigEndianAddr = false }()
+			asdu := new(ASDU)
+			signals, err := asdu.ParseASDU(asduBytes)
+			if err != nil {
+				t.Fatalf("ASDU.ParseASDU() error = %v", err)
+			}
+			if asdu.PublicAddress != tt.wantCommonAddr || signals[0].Address != tt.wantIOA {
+				t.Errorf("ASDU.ParseASDU() 公共地址 = %#x, 信息对象地址 = %#x, want %#x, %#x",
+					asdu.PublicAddress, signals[0].Address, tt.wantCommonAddr, tt.wantIOA)
+			}
+			//编码与解析使用同一字节序
+			if got := encodeCommandASDU(CScNa1, 6, 0, tt.wantCommonAddr, tt.wantIOA, nil); !bytes.Equal(got[4:9], asduBytes[4:9]) {
+				t.Errorf("encodeCommandASDU() 地址 = [% X], want [% X]", got[4:9], asduBytes[4:9])
+			}
+		})
+	}
+}
+
+func TestEncodeFrames(t *testing.T) {
+	ts := time.Date(2021, 3, 4, 5, 6, 7, 890000000, time.FixedZone("CST", 8*3600))
+	command := func(cmd Command, state CommandState) []byte {
+		return encodeCommandASDU(cmd.TypeID, 6, 0, cmd.CommonAddr, cmd.IOA, encodeCommandValue(cmd, state))
+	}
+	tests := []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{"测试启动激活", encodeUFrame(startDtAct), "68 04 07 00 00 00"},
+		{"测试启动确认", encodeUFrame(startDtCon), "68 04 0B 00 00 00"},
+		{"测试停止激活", encodeUFrame(stopDtAct), "68 04 13 00 00 00"},
+		{"测试停止确认", encodeUFrame(stopDtCon), "68 04 23 00 00 00"},
+		{"测试测试激活", encodeUFrame(testFrAct), "68 04 43 00 00 00"},
+		{"测试测试确认", encodeUFrame(testFrCon), "68 04 83 00 00 00"},
+		{"测试S帧", encodeSFrame(3), "68 04 01 00 06 00"},
+		{"测试最大接收序号的S帧", encodeSFrame(32767), "68 04 01 00 FE FF"},
+		{"测试总召唤", encodeIFrame(1, 2, encodeCommandASDU(CIcNa1, 6, 0, 1, 0, []byte{QoiStation})),
+			"68 0E 02 00 04 00 64 01 06 00 01 00 00 00 00 14"},
+		{"测试电度总召唤", encodeIFrame(0, 0, encodeCommandASDU(CCiNa1, 6, 0, 1, 0, []byte{RqtGeneral})),
+			"68 0E 00 00 00 00 65 01 06 00 01 00 00 00 00 05"},
+		{"测试单点命令选择", command(singleCommand(1, 0x6001, true, true), CommandSelecting), "2D 01 06 00 01 00 01 60 00 81"},
+		{"测试单点命令执行", command(singleCommand(1, 0x6001, false, true), CommandExecuting), "2D 01 06 00 01 00 01 60 00 00"},
+		{"测试带时标的单点命令", command(Command{TypeID: CScTa1, CommonAddr: 1, IOA: 0x6001, Value: []byte{0x01}, Time: ts}, CommandExecuting),
+			"3A 01 06 00 01 00 01 60 00 01 D2 1E 06 05 84 03 15"},
+		{"测试双点命令", command(doubleCommand(1, 0x6002, true, false), CommandExecuting), "2E 01 06 00 01 00 02 60 00 02"},
+		{"测试调节步命令选择", command(regulatingStepCommand(1, 0x6003, true, true), CommandSelecting), "2F 01 06 00 01 00 03 60 00 82"},
+		{"测试标度化值设定命令", command(scaledSetpointCommand(1, 0x6201, -2, 0, false), CommandExecuting), "31 01 06 00 01 00 01 62 00 FE FF 00"},
+		{"测试标度化值设定命令选择", command(scaledSetpointCommand(1, 0x6201, -2, 0, true), CommandSelecting), "31 01 06 00 01 00 01 62 00 FE FF 80"},
+		{"测试短浮点数设定命令", command(floatSetpointCommand(1, 0x6202, 1.5, 0, false), CommandExecuting), "32 01 06 00 01 00 02 62 00 00 00 C0 3F 00"},
+		{"测试32比特串命令", command(bitstring32Command(1, 0x6401, 0x80000001, ts), CommandExecuting),
+			"40 01 06 00 01 00 01 64 00 01 00 00 80 D2 1E 06 05 84 03 15"},
+		{"测试时钟同步命令", command(Command{TypeID: CCsNa1, CommonAddr: 1, Time: ts}, CommandExecuting),
+			"67 01 06 00 01 00 00 00 00 D2 1E 06 05 84 03 15"},
+		{"测试复位进程命令", command(Command{TypeID: CRpNa1, CommonAddr: 1, Value: []byte{QrpGeneral}}, CommandExecuting),
+			"69 01 06 00 01 00 00 00 00 01"},
+		{"测试撤销单点命令", encodeCommandASDU(CScNa1, 8, 0, 1, 0x6001, []byte{0x01}), "2D 01 08 00 01 00 01 60 00 01"},
+		{"测试读命令", encodeCommandASDU(CRdNa1, 5, 0, 1, 0x4001, nil), "66 01 05 00 01 00 01 40 00"},
+		{"测试召唤目录", encodeCommandASDU(FScNa1, 13, 0, 1, 0, []byte{0, 0, 0, 0}), "7A 01 0D 00 01 00 00 00 00 00 00 00 00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fmt.Sprintf("% X", tt.got); got != tt.want {
+				t.Errorf("编码结果 = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
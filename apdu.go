@@ -1,19 +1,26 @@
 package iec104
 
-import "fmt"
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
 
 //APDU 104数据包
 type APDU struct {
 	APCI     *APCI
-	ASDU     *ASDU
+	ASDU     *ASDU //S帧和U帧为nil
 	Len      int
 	ASDULen  int
 	CtrType  byte
 	CtrFrame interface{}
 	Signals  []*Signal
+	Raw      []byte //收到的完整帧,包含起始符和长度,为帧数据的副本
 }
 
-//parseAPDU 解析APDU
+//parseAPDU 解析APDU,ASDU解析失败时返回异常,已解析的控制域仍保存在APCI、CtrType和CtrFrame中
 func (apdu *APDU) parseAPDU(input []byte) error {
 	if input == nil || len(input) < 4 {
 		return fmt.Errorf("APDU报文[%X]非法", input)
@@ -29,24 +36,156 @@ func (apdu *APDU) parseAPDU(input []byte) error {
 	if err != nil {
 		return fmt.Errorf("APDU报文[%X]解析控制域异常: %v", input, err)
 	}
-	asdu := new(ASDU)
+	apdu.APCI = apci
+	apdu.Len = apci.ApduLen
+	apdu.CtrType = fType
+	apdu.CtrFrame = ctrFrame
+	var asdu *ASDU
 	var asduLen int
 	signals := make([]*Signal, 0)
-	if len(input[4:]) < 1 {
-		asduLen = 0
+	if len(input[4:]) == 0 {
+		//S帧和U帧只有控制域,没有ASDU
+		if fType == iFrame {
+			return fmt.Errorf("APDU报文[%X]为I帧但没有ASDU", input)
+		}
 	} else {
+		asdu = new(ASDU)
 		signals, err = asdu.ParseASDU(input[4:])
 		if err != nil {
 			return fmt.Errorf("APDU报文[%X]解析ASDU域[%X]异常: %v", input, input[4:], err)
 		}
 		asduLen = len(input[6:])
 	}
-	apdu.APCI = apci
 	apdu.ASDU = asdu
-	apdu.Len = apci.ApduLen
 	apdu.ASDULen = asduLen
-	apdu.CtrType = fType
-	apdu.CtrFrame = ctrFrame
 	apdu.Signals = signals
 	return nil
 }
+
+//DecodeAPDU 解析一个完整的APDU帧,包含起始符和长度,校验起始符和长度后返回解析结果
+func DecodeAPDU(raw []byte) (*APDU, error) {
+	if len(raw) < 2 || raw[0] != startFrame {
+		return nil, fmt.Errorf("APDU报文[%X]起始符非法", raw)
+	}
+	if int(raw[1]) != len(raw)-2 {
+		return nil, fmt.Errorf("APDU报文[%X]长度[%d]与实际长度[%d]不符", raw, raw[1], len(raw)-2)
+	}
+	apdu := new(APDU)
+	if err := apdu.parseAPDU(raw[2:]); err != nil {
+		return nil, err
+	}
+	apdu.Raw = append([]byte(nil), raw...)
+	return apdu, nil
+}
+
+//PeekASDUHeader 只解析完整I帧(包含起始符和长度)的ASDU报文头,不解析信息对象,用于按类型标识或公共地址快速分发。
+//cause为传输原因的低6位,S帧、U帧和长度不足的帧返回异常
+func PeekASDUHeader(raw []byte) (typeID byte, numObjects int, sequence bool, cause byte, commonAddr uint16, err error) {
+	if len(raw) < 2 || raw[0] != startFrame {
+		err = fmt.Errorf("APDU报文[%X]起始符非法", raw)
+		return
+	}
+	if len(raw) < 6 || raw[2]&1 != iFrame {
+		err = fmt.Errorf("APDU报文[%X]不是I帧", raw)
+		return
+	}
+	if len(raw) < 12 {
+		err = fmt.Errorf("APDU报文[%X]ASDU报文头长度不足", raw)
+		return
+	}
+	asdu := raw[6:]
+	typeID = asdu[0]
+	sequence = asdu[1]&0x80 == 0x80
+	numObjects = int(asdu[1] & 0x7f)
+	cause = asdu[2] & 0x3f
+	commonAddr = decodeCommonAddr(asdu[4:6])
+	return
+}
+
+//DecodeHexFrame 解析日志中复制的十六进制报文,如"68 0E 00 00 00 00 64 01 06 00 01 00 00 00 00 14",
+//忽略空白和常见分隔符
+func DecodeHexFrame(s string) (*APDU, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n', ':', '-', ',', '[', ']':
+			return -1
+		}
+		return r
+	}, s)
+	raw, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, fmt.Errorf("十六进制报文[%s]非法: %v", s, err)
+	}
+	return DecodeAPDU(raw)
+}
+
+//uFrameNames U帧功能名称
+var uFrameNames = map[[4]byte]string{
+	startDtAct: "启动激活",
+	startDtCon: "启动确认",
+	stopDtAct:  "停止激活",
+	stopDtCon:  "停止确认",
+	testFrAct:  "测试激活",
+	testFrCon:  "测试确认",
+}
+
+//String 报文的可读解释,I帧每个信息对象占一行
+func (apdu *APDU) String() string {
+	var b strings.Builder
+	switch f := apdu.CtrFrame.(type) {
+	case IFrame:
+		fmt.Fprintf(&b, "I帧 发送序号:%d 接收序号:%d", f.Send, f.Recv)
+	case SFrame:
+		fmt.Fprintf(&b, "S帧 接收序号:%d", f.Recv)
+	case UFrame:
+		name, ok := uFrameNames[f.cmd]
+		if !ok {
+			name = fmt.Sprintf("未知功能[% X]", f.cmd)
+		}
+		fmt.Fprintf(&b, "U帧 %s", name)
+	default:
+		b.WriteString("未知帧")
+	}
+	if apdu.ASDU == nil {
+		return b.String()
+	}
+	asdu := apdu.ASDU
+	fmt.Fprintf(&b, " 类型:%s 传输原因:%d 公共地址:%d", TypeIDName(asdu.TypeID), asdu.Cause, asdu.PublicAddress)
+	if asdu.Negative {
+		b.WriteString(" 否定确认")
+	}
+	if asdu.Test {
+		b.WriteString(" 试验")
+	}
+	for _, s := range apdu.Signals {
+		fmt.Fprintf(&b, "\n  信息对象地址:%d 值:%v 品质:0x%02X", s.Address, s.Value, s.Quality)
+		if s.Ts != 0 {
+			fmt.Fprintf(&b, " 时标:%s", time.Unix(0, int64(s.Ts*float64(time.Second))).Format("2006-01-02 15:04:05.000"))
+		}
+	}
+	return b.String()
+}
+
+//ReadFrame 从r中读取一个完整的APDU帧,包含起始符和长度,收到的字节少于长度字段时返回*TruncatedFrameError
+func ReadFrame(r io.Reader) ([]byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[0] != startFrame {
+		return nil, fmt.Errorf("起始符[%X]非法", header[0])
+	}
+	length := int(header[1])
+	if length < 4 {
+		return nil, fmt.Errorf("APDU长度[%d]非法", length)
+	}
+	frame := make([]byte, 2+length)
+	copy(frame, header)
+	if n, err := io.ReadFull(r, frame[2:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, truncatedFrame(frame, 2+n, err)
+	}
+	return frame, nil
+}
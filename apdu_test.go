@@ -0,0 +1,136 @@
+package iec104
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestAPDU_parseAPDU(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     []byte
+		wantType  byte
+		wantFrame interface{}
+		wantErr   bool
+	}{
+		{"测试S帧", []byte{0x01, 0x00, 0x04, 0x00}, sFrame, SFrame{Recv: 2}, false},
+		{"测试启动确认U帧", startDtCon[:], uFrame, UFrame{cmd: startDtCon}, false},
+		{"测试测试激活U帧", testFrAct[:], uFrame, UFrame{cmd: testFrAct}, false},
+		{"测试没有ASDU的I帧", []byte{0x02, 0x00, 0x04, 0x00}, 0, nil, true},
+		{"测试控制域不完整", []byte{0x01, 0x00}, 0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apdu := new(APDU)
+			err := apdu.parseAPDU(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("APDU.parseAPDU() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if apdu.ASDU != nil || len(apdu.Signals) != 0 || apdu.ASDULen != 0 {
+				t.Errorf("APDU.parseAPDU() ASDU = %v, signals = %v, want nil ASDU", apdu.ASDU, apdu.Signals)
+			}
+			if apdu.CtrType != tt.wantType || !reflect.DeepEqual(apdu.CtrFrame, tt.wantFrame) {
+				t.Errorf("APDU.parseAPDU() = %d, %+v, want %d, %+v", apdu.CtrType, apdu.CtrFrame, tt.wantType, tt.wantFrame)
+			}
+		})
+	}
+}
+
+func TestDecodeAPDU(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        []byte
+		wantSignal int
+		wantErr    bool
+	}{
+		{"测试I帧", []byte{0x68, 0x0E, 0x00, 0x00, 0x02, 0x00, 0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01}, 1, false},
+		{"测试U帧", []byte{0x68, 0x04, 0x0B, 0x00, 0x00, 0x00}, 0, false},
+		{"测试起始符非法", []byte{0x67, 0x04, 0x0B, 0x00, 0x00, 0x00}, 0, true},
+		{"测试长度与实际长度不符", []byte{0x68, 0x05, 0x0B, 0x00, 0x00, 0x00}, 0, true},
+		{"测试空报文", []byte{}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apdu, err := DecodeAPDU(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeAPDU() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(apdu.Signals) != tt.wantSignal {
+				t.Errorf("DecodeAPDU() got %d signals, want %d", len(apdu.Signals), tt.wantSignal)
+			}
+			if !bytes.Equal(apdu.Raw, tt.raw) || (len(tt.raw) > 0 && &apdu.Raw[0] == &tt.raw[0]) {
+				t.Errorf("DecodeAPDU() Raw = [% X], want copy of [% X]", apdu.Raw, tt.raw)
+			}
+		})
+	}
+}
+
+func TestDecodeHexFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{"测试空格分隔的总召唤", "68 0E 00 00 00 00 64 01 06 00 01 00 00 00 00 14", "I帧 发送序号:0 接收序号:0 类型:C_IC_NA_1 传输原因:6 公共地址:1\n  信息对象地址:0 值:20 品质:0x00", false},
+		{"测试冒号分隔的启动确认", "68:04:0B:00:00:00", "U帧 启动确认", false},
+		{"测试S帧", "[68 04 01 00 04 00]", "S帧 接收序号:2", false},
+		{"测试单点遥信", "680E0000020001010300010001000001", "I帧 发送序号:0 接收序号:1 类型:M_SP_NA_1 传输原因:3 公共地址:1\n  信息对象地址:1 值:1 品质:0x00", false},
+		{"测试非十六进制字符", "68 0G", "", true},
+		{"测试长度不符", "68 05 0B 00 00 00", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apdu, err := DecodeHexFrame(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeHexFrame() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := apdu.String(); got != tt.want {
+				t.Errorf("APDU.String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeekASDUHeader(t *testing.T) {
+	tests := []struct {
+		name           string
+		raw            []byte
+		wantTypeID     byte
+		wantNumObjects int
+		wantSequence   bool
+		wantCause      byte
+		wantCommonAddr uint16
+		wantErr        bool
+	}{
+		{"测试连续浮点遥测", []byte{0x68, 0x14, 0x00, 0x00, 0x00, 0x00, 0x0d, 0x82, 0x14, 0x00, 0x02, 0x01, 0x01, 0x40, 0x00,
+			0x00, 0x00, 0x80, 0x3f, 0x00}, MMeNc1, 2, true, 20, 0x0102, false},
+		{"测试否定确认", []byte{0x68, 0x0e, 0x00, 0x00, 0x00, 0x00, 0x64, 0x01, 0x47, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x14},
+			CIcNa1, 1, false, 7, 1, false},
+		{"测试U帧", []byte{0x68, 0x04, 0x0b, 0x00, 0x00, 0x00}, 0, 0, false, 0, 0, true},
+		{"测试报文头不完整", []byte{0x68, 0x08, 0x00, 0x00, 0x00, 0x00, 0x0d, 0x01, 0x03, 0x00}, 0, 0, false, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typeID, numObjects, sequence, cause, commonAddr, err := PeekASDUHeader(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PeekASDUHeader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if typeID != tt.wantTypeID || numObjects != tt.wantNumObjects || sequence != tt.wantSequence ||
+				cause != tt.wantCause || commonAddr != tt.wantCommonAddr {
+				t.Errorf("PeekASDUHeader() = %d, %d, %v, %d, %d, want %d, %d, %v, %d, %d", typeID, numObjects, sequence, cause, commonAddr,
+					tt.wantTypeID, tt.wantNumObjects, tt.wantSequence, tt.wantCause, tt.wantCommonAddr)
+			}
+		})
+	}
+}
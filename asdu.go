@@ -2,9 +2,10 @@ package iec104
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
-	"log"
 	"math"
+	"sort"
 	"time"
 )
 
@@ -13,9 +14,26 @@ type ASDU struct {
 	TypeID        byte    //类型标识
 	Sequence      bool    //是否连续
 	Length        byte    //可变结构限定词
-	Cause         uint16  //传输原因
+	Cause         Cause   //传输原因
+	Negative      bool    //是否否定确认
+	Test          bool    //是否为试验(传输原因T位),试验数据不应写入运行数据库
+	Originator    byte    //源发地址
 	PublicAddress uint16  //公共地址
 	Ts            float64 //毫秒级时间戳
+	signals       []*Signal
+	ObjectError   *ObjectError //宽松解析时第一个无法解析的信息对象,为nil时全部解析成功
+}
+
+//ObjectError 宽松解析(LenientParse)时无法解析的信息对象,之前的信息对象正常解析,之后的被丢弃
+type ObjectError struct {
+	Index  int //信息对象序号,从0开始
+	Offset int //在ASDU中的字节偏移
+	Err    error
+}
+
+//Error 实现error接口
+func (e *ObjectError) Error() string {
+	return fmt.Sprintf("第%d个信息对象(偏移%d)解析异常: %v", e.Index+1, e.Offset, e.Err)
 }
 
 //数据类型
@@ -24,26 +42,191 @@ const (
 	MSpNa1 = 1
 	//MDpNa1 不带时标的双点遥信，每个遥信占1个字节
 	MDpNa1 = 3
+	//MStNa1 步位置信息,1个字节带瞬变状态指示的值VTI,1个字节品质描述
+	MStNa1 = 5
 	//MMeNc1 带品质描述的测量值，每个遥测值占3个字节
 	MMeNa1 = 9
+	//MMeNb1 测量值,标度化值,每个遥测值占3个字节
+	MMeNb1 = 11
+	//MPsNa1 带变位检出的成组单点信息,每个信息对象占4个字节状态和变位检出,1个字节品质描述
+	MPsNa1 = 20
 	//MMeNc1 带品质描述的浮点值，每个遥测值占5个字节
 	MMeNc1 = 13
+	//MMeNd1 不带品质描述的测量值,归一化值,每个遥测值只有2个字节,品质视为有效
+	MMeNd1 = 21
 	//MItNa1 电度总量,每个遥脉值占5个字节
 	MItNa1 = 15
 	//MSpTb1 带游标的单点遥信，3个字节的地址，1个字节的值，7个字节短时标
 	MSpTb1 = 30
+	//MStTb1 带时标CP56Time2a的步位置信息,1个字节VTI,1个字节品质描述,7个字节时标
+	MStTb1 = 32
+	//MMeTd1 带时标CP56Time2a的测量值,归一化值,2个字节的值,1个字节品质描述,7个字节时标
+	MMeTd1 = 34
+	//MMeTe1 带时标CP56Time2a的测量值,标度化值,2个字节的值,1个字节品质描述,7个字节时标
+	MMeTe1 = 35
+	//MMeTf1 带时标CP56Time2a的测量值,短浮点数,4个字节的值,1个字节品质描述,7个字节时标
+	MMeTf1 = 36
+	//MItTb1 带时标CP56Time2a的累计量,每个遥脉值占5个字节，7个字节时标
+	MItTb1 = 37
+	//MEpTd1 带时标CP56Time2a的继电保护设备事件,1个字节事件,2个字节经过时间,7个字节时标
+	MEpTd1 = 38
+	//MEpTe1 带时标CP56Time2a的继电保护设备成组启动事件,1个字节启动事件,1个字节品质,2个字节持续时间,7个字节时标
+	MEpTe1 = 39
+	//MEpTf1 带时标CP56Time2a的继电保护设备成组输出电路信息,1个字节输出电路信息,1个字节品质,2个字节动作时间,7个字节时标
+	MEpTf1 = 40
 	//MEiNA1 初始化结束
 	MEiNA1 = 70
+	//CScNa1 单点命令
+	CScNa1 = 45
+	//CDcNa1 双点命令
+	CDcNa1 = 46
+	//CRcNa1 调节步命令
+	CRcNa1 = 47
+	//CSeNb1 设定值命令,标度化值,2个字节的设定值,1个字节的设定命令限定词
+	CSeNb1 = 49
+	//CSeNc1 设定值命令,短浮点数,4个字节的设定值,1个字节的设定命令限定词
+	CSeNc1 = 50
+	//CScTa1 带时标CP56Time2a的单点命令
+	CScTa1 = 58
+	//CBoTa1 带时标CP56Time2a的32比特串命令,4个字节的比特串,没有命令限定词,7个字节时标
+	CBoTa1 = 64
 	//CIcNa1 总召唤
 	CIcNa1 = 100
 	//CCiNa1 电度总召唤
 	CCiNa1 = 101
+	//CRdNa1 读命令,只有信息对象地址
+	CRdNa1 = 102
+	//CCsNa1 时钟同步命令,信息对象地址为0,7个字节时标
+	CCsNa1 = 103
+	//CRpNa1 复位进程命令,信息对象地址为0,1个字节复位进程命令限定词QRP
+	CRpNa1 = 105
+	//FScNa1 召唤目录、选择文件、召唤文件或节,2个字节文件名称,1个字节节名称,1个字节选择召唤限定词SCQ
+	FScNa1 = 122
+	//FDrTa1 目录,2个字节文件名称,3个字节文件长度,1个字节文件状态,7个字节时标
+	FDrTa1 = 126
 )
 
+//TypeIDInfo 支持解析的类型标识
+type TypeIDInfo struct {
+	TypeID      byte   `json:"type_id"`
+	Name        string `json:"name"`         //IEC名称
+	Control     bool   `json:"control"`      //是否为控制方向,否则为监视方向
+	ElementSize int    `json:"element_size"` //单个信息元素的字节数,不含3个字节的信息对象地址
+	TimeTagged  bool   `json:"time_tagged"`  //是否带时标
+}
+
+//typeIDList 解析器支持的类型标识,新增类型时在此登记
+var typeIDList = []TypeIDInfo{
+	{MSpNa1, "M_SP_NA_1", false, 1, false},
+	{MDpNa1, "M_DP_NA_1", false, 1, false},
+	{MStNa1, "M_ST_NA_1", false, 2, false},
+	{MMeNa1, "M_ME_NA_1", false, 3, false},
+	{MMeNb1, "M_ME_NB_1", false, 3, false},
+	{MMeNc1, "M_ME_NC_1", false, 5, false},
+	{MMeNd1, "M_ME_ND_1", false, 2, false},
+	{MItNa1, "M_IT_NA_1", false, 5, false},
+	{MPsNa1, "M_PS_NA_1", false, 5, false},
+	{MSpTb1, "M_SP_TB_1", false, 8, true},
+	{MStTb1, "M_ST_TB_1", false, 9, true},
+	{MMeTd1, "M_ME_TD_1", false, 10, true},
+	{MMeTe1, "M_ME_TE_1", false, 10, true},
+	{MMeTf1, "M_ME_TF_1", false, 12, true},
+	{MItTb1, "M_IT_TB_1", false, 12, true},
+	{MEpTd1, "M_EP_TD_1", false, 10, true},
+	{MEpTe1, "M_EP_TE_1", false, 11, true},
+	{MEpTf1, "M_EP_TF_1", false, 11, true},
+	{CScNa1, "C_SC_NA_1", true, 1, false},
+	{CDcNa1, "C_DC_NA_1", true, 1, false},
+	{CRcNa1, "C_RC_NA_1", true, 1, false},
+	{CSeNb1, "C_SE_NB_1", true, 3, false},
+	{CSeNc1, "C_SE_NC_1", true, 5, false},
+	{CScTa1, "C_SC_TA_1", true, 8, true},
+	{CBoTa1, "C_BO_TA_1", true, 11, true},
+	{MEiNA1, "M_EI_NA_1", false, 1, false},
+	{CIcNa1, "C_IC_NA_1", true, 1, false},
+	{CCiNa1, "C_CI_NA_1", true, 1, false},
+	{CRdNa1, "C_RD_NA_1", true, 0, false},
+	{CCsNa1, "C_CS_NA_1", true, 7, true},
+	{CRpNa1, "C_RP_NA_1", true, 1, false},
+	{FScNa1, "F_SC_NA_1", true, 4, false},
+	{FDrTa1, "F_DR_TA_1", false, 13, true},
+}
+
+//typeIDs 以类型标识为键的typeIDList
+var typeIDs = func() map[byte]TypeIDInfo {
+	m := make(map[byte]TypeIDInfo, len(typeIDList))
+	for _, info := range typeIDList {
+		m[info.TypeID] = info
+	}
+	return m
+}()
+
+//sequenceTypeIDs 支持信息对象连续排列(SQ=1)解析的类型标识
+var sequenceTypeIDs = map[byte]bool{
+	MSpNa1: true,
+	MDpNa1: true,
+	MStNa1: true,
+	MMeNa1: true,
+	MMeNb1: true,
+	MMeNc1: true,
+	MMeNd1: true,
+	MItNa1: true,
+	MPsNa1: true,
+	CScNa1: true,
+	CDcNa1: true,
+	CRcNa1: true,
+	CSeNb1: true,
+	CSeNc1: true,
+	FDrTa1: true,
+}
+
+//SupportedTypeIDs 返回支持解析的类型标识,按类型标识排序,不含RegisterElementDecoder注册的私有类型
+func SupportedTypeIDs() []TypeIDInfo {
+	infos := append([]TypeIDInfo(nil), typeIDList...)
+	sort.Slice(infos, func(i, j int) bool { return infos[i].TypeID < infos[j].TypeID })
+	return infos
+}
+
+//checkLength 校验可变结构限定词中的信息对象数量不超过报文实际长度
+func (asdu *ASDU) checkLength(asduBytes []byte) error {
+	info, ok := typeIDs[asdu.TypeID]
+	if !ok {
+		return nil
+	}
+	size := info.ElementSize
+	n := int(asdu.Length)
+	want := 6 + n*(3+size)
+	//不支持连续排列的类型即使SQ=1也按每个信息对象带地址解析
+	if asdu.Sequence && n > 0 && sequenceTypeIDs[asdu.TypeID] {
+		want = 6 + 3 + n*size
+	}
+	if len(asduBytes) < want {
+		return fmt.Errorf("asdu类型[%d]信息对象数量[%d]需要%d字节,实际长度%d字节", asdu.TypeID, n, want, len(asduBytes))
+	}
+	return nil
+}
+
+//salvage 长度不足时返回完整的信息对象数量,并将第一个不完整的信息对象记录到ObjectError
+func (asdu *ASDU) salvage(asduBytes []byte, err error) int {
+	size := typeIDs[asdu.TypeID].ElementSize
+	n, offset := 0, 6
+	if asdu.Sequence && sequenceTypeIDs[asdu.TypeID] {
+		if len(asduBytes) >= 9 {
+			n = (len(asduBytes) - 9) / size
+			offset = 9 + n*size
+		}
+	} else {
+		n = (len(asduBytes) - 6) / (3 + size)
+		offset = 6 + n*(3+size)
+	}
+	asdu.ObjectError = &ObjectError{Index: n, Offset: offset, Err: err}
+	return n
+}
+
 // ParseASDU 解析asdu
 func (asdu *ASDU) ParseASDU(asduBytes []byte) (signals []*Signal, err error) {
 	signals = make([]*Signal, 0, 0)
-	if asduBytes == nil || len(asduBytes) < 4 {
+	if asduBytes == nil || len(asduBytes) < 6 {
 		err = fmt.Errorf("asdu[%X]非法", asduBytes)
 		return
 	}
@@ -52,15 +235,33 @@ func (asdu *ASDU) ParseASDU(asduBytes []byte) (signals []*Signal, err error) {
 	asdu.Sequence, asdu.Length = asdu.ParseVariable(asduBytes[1])
 	var firstAddress uint32
 
-	asdu.Cause = binary.LittleEndian.Uint16([]byte{asduBytes[2], asduBytes[3]})
-	asdu.PublicAddress = binary.LittleEndian.Uint16([]byte{asduBytes[4], asduBytes[5]})
+	//传输原因低6位为原因，第7位为否定确认位，第8位为试验位
+	asdu.Cause = Cause(asduBytes[2] & 0x3f)
+	asdu.Negative = asduBytes[2]&0x40 == 0x40
+	asdu.Test = asduBytes[2]&testBit == testBit
+	asdu.Originator = asduBytes[3]
+	asdu.PublicAddress = decodeCommonAddr(asduBytes[4:6])
+	n := int(asdu.Length)
+	if err = asdu.checkLength(asduBytes); err != nil {
+		if !LenientParse {
+			return
+		}
+		n = asdu.salvage(asduBytes, err)
+		err = nil
+	}
+	if decoder, ok := lookupElementDecoder(asdu.TypeID); ok {
+		signals, err = asdu.parseCustom(asduBytes, decoder, n)
+		asdu.signals = signals
+		return
+	}
 
-	if asdu.Sequence {
-		firstAddress = binary.LittleEndian.Uint32([]byte{asduBytes[6], asduBytes[7], asduBytes[8], 0x00})
+	if asdu.Sequence && len(asduBytes) >= 9 {
+		firstAddress = decodeIOA(asduBytes[6:])
 	}
-	for i := 0; i < int(asdu.Length); i++ {
+	for i := 0; i < n; i++ {
 		s := new(Signal)
 		s.TypeID = uint(asdu.TypeID)
+		s.Cause = asdu.Cause
 		if asdu.Sequence {
 			s.Address = firstAddress
 			firstAddress++
@@ -71,58 +272,212 @@ func (asdu *ASDU) ParseASDU(asduBytes []byte) (signals []*Signal, err error) {
 			if asdu.Sequence {
 				s.Value = float64(asduBytes[9+i])
 			} else {
-				s.Address = binary.LittleEndian.Uint32([]byte{asduBytes[6+i*size], asduBytes[6+i*size+1], asduBytes[6+i*size+2], 0x00})
+				s.Address = decodeIOA(asduBytes[6+i*size:])
 				s.Value = float64(asduBytes[6+i*size+3])
 			}
-		case MMeNa1:
-			size := 6
+		case MStNa1:
+			//Value为步位置,Data为带瞬变状态的步位置
+			var element []byte
 			if asdu.Sequence {
-				size := 3
-				s.Value = float64(binary.LittleEndian.Uint16([]byte{asduBytes[9+i*size], asduBytes[9+i*size+1]}))
-				s.Quality = asduBytes[9+i*size+2]
+				size := 2
+				element = asduBytes[9+i*size : 9+(i+1)*size]
 			} else {
-				s.Address = binary.LittleEndian.Uint32([]byte{asduBytes[6+i*size], asduBytes[6+i*size+1], asduBytes[6+i*size+2], 0x00})
-				s.Value = float64(binary.LittleEndian.Uint16([]byte{asduBytes[6+i*size+3], asduBytes[6+i*size+4]}))
-				s.Quality = asduBytes[6+i*size+5]
+				size := 5
+				s.Address = decodeIOA(asduBytes[6+i*size:])
+				element = asduBytes[6+i*size+3 : 6+(i+1)*size]
 			}
-		case MMeNc1:
+			s.setStepPosition(element[0])
+			s.Quality = element[1]
+		case MStTb1:
+			size := 12
+			s.Address = decodeIOA(asduBytes[6+i*size:])
+			s.setStepPosition(asduBytes[6+i*size+3])
+			s.Quality = asduBytes[6+i*size+4]
+			s.setTime(asduBytes[6+i*size+5 : 6+i*size+12])
+		case MMeNa1, MMeNb1, MMeNc1:
+			//测量值加1个字节的品质描述词,连续时每个信息对象仍带品质描述词
+			size := typeIDs[asdu.TypeID].ElementSize
+			var element []byte
+			if asdu.Sequence {
+				element = asduBytes[9+i*size : 9+(i+1)*size]
+			} else {
+				s.Address = decodeIOA(asduBytes[6+i*(3+size):])
+				element = asduBytes[6+i*(3+size)+3 : 6+(i+1)*(3+size)]
+			}
+			s.setMeasuredValue(asdu.TypeID, element)
+		case MMeNd1:
+			//只有2个字节的归一化值,没有品质描述词,不能按类型9的偏移解析
+			size := typeIDs[asdu.TypeID].ElementSize
+			var element []byte
+			if asdu.Sequence {
+				element = asduBytes[9+i*size : 9+(i+1)*size]
+			} else {
+				s.Address = decodeIOA(asduBytes[6+i*(3+size):])
+				element = asduBytes[6+i*(3+size)+3 : 6+(i+1)*(3+size)]
+			}
+			s.Value = float64(binary.LittleEndian.Uint16(element))
+		case MMeTd1, MMeTe1, MMeTf1:
+			size := 3 + typeIDs[asdu.TypeID].ElementSize
+			s.Address = decodeIOA(asduBytes[6+i*size:])
+			element := asduBytes[6+i*size+3 : 6+(i+1)*size]
+			s.setMeasuredValue(asdu.TypeID, element[:len(element)-7])
+			s.setTime(element[len(element)-7:])
+		case MItNa1:
+			//4个字节的二进制计数读数加1个字节的顺序记法
 			size := 8
 			if asdu.Sequence {
 				size := 5
-				s.Value = float64(math.Float32frombits(binary.LittleEndian.Uint32([]byte{asduBytes[9+i*size], asduBytes[9+i*size+1],
+				s.Value = float64(int32(binary.LittleEndian.Uint32([]byte{asduBytes[9+i*size], asduBytes[9+i*size+1],
 					asduBytes[9+i*size+2], asduBytes[9+i*size+3]})))
 				s.Quality = asduBytes[9+i*size+4]
 			} else {
-				s.Address = binary.LittleEndian.Uint32([]byte{asduBytes[6+i*size], asduBytes[6+i*size+1], asduBytes[6+i*size+2], 0x00})
-				s.Value = float64(math.Float32frombits(binary.LittleEndian.Uint32([]byte{asduBytes[6+i*size+3], asduBytes[6+i*size+4],
+				s.Address = decodeIOA(asduBytes[6+i*size:])
+				s.Value = float64(int32(binary.LittleEndian.Uint32([]byte{asduBytes[6+i*size+3], asduBytes[6+i*size+4],
 					asduBytes[6+i*size+5], asduBytes[6+i*size+6]})))
 				s.Quality = asduBytes[6+i*size+7]
 			}
-		case MItNa1:
-			size := 8
+		case MItTb1:
+			size := 15
+			s.Address = decodeIOA(asduBytes[6+i*size:])
+			s.Value = float64(int32(binary.LittleEndian.Uint32([]byte{asduBytes[6+i*size+3], asduBytes[6+i*size+4],
+				asduBytes[6+i*size+5], asduBytes[6+i*size+6]})))
+			s.Quality = asduBytes[6+i*size+7]
+			s.setTime(asduBytes[6+i*size+8 : 6+i*size+15])
+		case MPsNa1:
+			//4个字节的状态和变位检出加1个字节的品质描述词,Value为16个单点的状态
+			var element []byte
 			if asdu.Sequence {
 				size := 5
-				s.Value = float64(binary.LittleEndian.Uint32([]byte{asduBytes[9+i*size], asduBytes[9+i*size+1],
-					asduBytes[9+i*size+2], asduBytes[9+i*size+3]}))
+				element = asduBytes[9+i*size : 9+(i+1)*size]
 			} else {
-				s.Address = binary.LittleEndian.Uint32([]byte{asduBytes[6+i*size], asduBytes[6+i*size+1], asduBytes[6+i*size+2], 0x00})
-				s.Value = float64(binary.LittleEndian.Uint32([]byte{asduBytes[6+i*size+3], asduBytes[9+i*size+4],
-					asduBytes[9+i*size+5], asduBytes[9+i*size+6]}))
+				size := 8
+				s.Address = decodeIOA(asduBytes[6+i*size:])
+				element = asduBytes[6+i*size+3 : 6+(i+1)*size]
 			}
+			s.Value = float64(binary.LittleEndian.Uint16(element[:2]))
+			s.Quality = element[4]
+			s.Data = ParsePackedSinglePoint(element[:4])
 		case MSpTb1:
 			size := 11
-			s.Address = binary.LittleEndian.Uint32([]byte{asduBytes[6+i*size], asduBytes[6+i*size+1], asduBytes[6+i*size+2], 0x00})
+			s.Address = decodeIOA(asduBytes[6+i*size:])
 			s.Value = float64(asduBytes[6+i*size+3])
-			s.Ts = asdu.ParseTime(asduBytes[6+i*size+4 : 6+i*size+11])
-		case CIcNa1, CCiNa1, MEiNA1:
+			s.setTime(asduBytes[6+i*size+4 : 6+i*size+11])
+		case MEpTd1, MEpTe1, MEpTf1:
+			size := 3 + typeIDs[asdu.TypeID].ElementSize
+			s.Address = decodeIOA(asduBytes[6+i*size:])
+			asdu.parseProtectionEvent(s, asduBytes[6+i*size+3:6+(i+1)*size])
+		case CScNa1, CDcNa1, CRcNa1:
+			size := 4
+			if asdu.Sequence {
+				s.Value = float64(asduBytes[9+i])
+			} else {
+				s.Address = decodeIOA(asduBytes[6+i*size:])
+				s.Value = float64(asduBytes[6+i*size+3])
+			}
+		case CIcNa1, CCiNa1, CRpNa1:
+			size := 4
+			s.Address = decodeIOA(asduBytes[6+i*size:])
+			s.Value = float64(asduBytes[6+i*size+3])
+		case CSeNb1:
+			//Value为设定值,Quality为设定命令限定词QOS
+			var element []byte
+			if asdu.Sequence {
+				size := 3
+				element = asduBytes[9+i*size : 9+(i+1)*size]
+			} else {
+				size := 6
+				s.Address = decodeIOA(asduBytes[6+i*size:])
+				element = asduBytes[6+i*size+3 : 6+(i+1)*size]
+			}
+			s.Value = float64(int16(binary.LittleEndian.Uint16(element[:2])))
+			s.Quality = element[2]
+		case CSeNc1:
+			var element []byte
+			if asdu.Sequence {
+				size := 5
+				element = asduBytes[9+i*size : 9+(i+1)*size]
+			} else {
+				size := 8
+				s.Address = decodeIOA(asduBytes[6+i*size:])
+				element = asduBytes[6+i*size+3 : 6+(i+1)*size]
+			}
+			s.Value = float64(math.Float32frombits(binary.LittleEndian.Uint32(element[:4])))
+			s.Quality = element[4]
+		case CRdNa1:
+			size := 3
+			s.Address = decodeIOA(asduBytes[6+i*size:])
+		case CCsNa1:
+			size := 10
+			s.Address = decodeIOA(asduBytes[6+i*size:])
+			s.setTime(asduBytes[6+i*size+3 : 6+i*size+10])
+		case MEiNA1:
+			size := 4
+			s.Address = decodeIOA(asduBytes[6+i*size:])
+			s.Value = float64(asduBytes[6+i*size+3])
+			e := new(EndOfInit)
+			e.Cause, e.LocalChange = ParseCOI(asduBytes[6+i*size+3])
+			s.Data = e
+		case CScTa1:
+			size := 11
+			s.Address = decodeIOA(asduBytes[6+i*size:])
+			s.Value = float64(asduBytes[6+i*size+3])
+			s.setTime(asduBytes[6+i*size+4 : 6+i*size+11])
+		case CBoTa1:
+			size := 14
+			s.Address = decodeIOA(asduBytes[6+i*size:])
+			s.Value = float64(binary.LittleEndian.Uint32(asduBytes[6+i*size+3 : 6+i*size+7]))
+			s.setTime(asduBytes[6+i*size+7 : 6+i*size+14])
+		case FScNa1:
+			//Value为文件名称,Quality为选择召唤限定词SCQ
+			size := 7
+			s.Address = decodeIOA(asduBytes[6+i*size:])
+			s.Value = float64(binary.LittleEndian.Uint16(asduBytes[6+i*size+3 : 6+i*size+5]))
+			s.Quality = asduBytes[6+i*size+6]
+		case FDrTa1:
+			var element []byte
+			if asdu.Sequence {
+				size := 13
+				element = asduBytes[9+i*size : 9+(i+1)*size]
+			} else {
+				size := 16
+				s.Address = decodeIOA(asduBytes[6+i*size:])
+				element = asduBytes[6+i*size+3 : 6+(i+1)*size]
+			}
+			s.parseFileInfo(element)
 		default:
-			log.Fatalln("暂不支持的数据类型:", asdu.TypeID)
+			return nil, fmt.Errorf("暂不支持的数据类型:%d(%s)", asdu.TypeID, TypeIDName(asdu.TypeID))
 		}
 		signals = append(signals, s)
 	}
+	asdu.signals = signals
 	return
 }
 
+// MarshalJSON 序列化asdu及其信息对象，用于日志和数据转发
+func (asdu *ASDU) MarshalJSON() ([]byte, error) {
+	signals := asdu.signals
+	if signals == nil {
+		signals = make([]*Signal, 0)
+	}
+	return json.Marshal(struct {
+		TypeID        byte      `json:"type_id"`
+		TypeName      string    `json:"type_name"`
+		Sequence      bool      `json:"sequence"`
+		Cause         Cause     `json:"cause"`
+		Test          bool      `json:"test,omitempty"`
+		PublicAddress uint16    `json:"public_address"`
+		Signals       []*Signal `json:"signals"`
+	}{
+		TypeID:        asdu.TypeID,
+		TypeName:      TypeIDName(asdu.TypeID),
+		Sequence:      asdu.Sequence,
+		Cause:         asdu.Cause,
+		Test:          asdu.Test,
+		PublicAddress: asdu.PublicAddress,
+		Signals:       signals,
+	})
+}
+
 // ParseVariable 解析asdu可变结构限定词
 func (asdu *ASDU) ParseVariable(b byte) (sq bool, length byte) {
 	//最高位是否为1
@@ -137,16 +492,80 @@ func (asdu *ASDU) ParseVariable(b byte) (sq bool, length byte) {
 
 // ParseTime 解析asdu中7个字节时表,转为带毫秒的时间戳
 func (asdu *ASDU) ParseTime(asduBytes []byte) float64 {
-	if len(asduBytes) != 7 {
-		return 0
+	t, _ := ParseCP56Time2a(asduBytes)
+	return unixSeconds(t)
+}
+
+//ParseCP56Time2a 按本地时区解析7个字节时标,summer为夏令时标志SU(小时字节最高位)。
+//夏令时结束时重复的一小时按SU区分,长度不为7时返回零值
+func ParseCP56Time2a(b []byte) (t time.Time, summer bool) {
+	return parseCP56Time2a(b, time.Local)
+}
+
+func parseCP56Time2a(b []byte, loc *time.Location) (t time.Time, summer bool) {
+	if len(b) != 7 {
+		return
 	}
-	milliseconds := binary.LittleEndian.Uint16([]byte{asduBytes[0], asduBytes[1]})
+	milliseconds := binary.LittleEndian.Uint16([]byte{b[0], b[1]})
 	nanosecond := (int(milliseconds) % 1000) * 1000000
 	second := int(milliseconds / 1000)
-	minute := int(asduBytes[2])
-	hour := int(asduBytes[3])
-	day := int(asduBytes[4])
-	month := int(asduBytes[5])
-	year := int(asduBytes[6]) + 2000
-	return float64(time.Date(year, time.Month(month), day, hour, minute, second, nanosecond, time.Local).Unix()) + float64(nanosecond)/1000000000.0
+	minute := int(b[2] & 0x3f)
+	hour := int(b[3] & 0x1f)
+	day := int(b[4] & 0x1f)
+	month := int(b[5] & 0x0f)
+	year := int(b[6]&0x7f) + 2000
+	summer = b[3]&0x80 != 0
+	t = time.Date(year, time.Month(month), day, hour, minute, second, nanosecond, loc)
+	if summerTime(t) != summer {
+		//重复的一小时,取另一个与SU一致的时刻
+		for _, d := range []time.Duration{-time.Hour, time.Hour} {
+			alt := t.Add(d)
+			if summerTime(alt) == summer && alt.Hour() == hour && alt.Minute() == minute {
+				t = alt
+				break
+			}
+		}
+	}
+	return
+}
+
+//summerTime t所在时区是否处于夏令时,即偏移大于当年1月和7月中较小的偏移
+func summerTime(t time.Time) bool {
+	_, offset := t.Zone()
+	_, jan := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location()).Zone()
+	_, jul := time.Date(t.Year(), 7, 1, 0, 0, 0, 0, t.Location()).Zone()
+	if jul < jan {
+		jan = jul
+	}
+	return offset > jan
+}
+
+//unixSeconds 转为带毫秒的时间戳,零值为0
+func unixSeconds(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.Unix()) + float64(t.Nanosecond())/1000000000.0
+}
+
+//encodeCP56Time2a 将时间编码为7个字节时标,星期一至星期日为1~7,t所在时区处于夏令时时置SU位
+func encodeCP56Time2a(t time.Time) []byte {
+	milliseconds := uint16(t.Second()*1000 + t.Nanosecond()/1000000)
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	data := make([]byte, 0, 7)
+	data = append(data, parseLittleEndianUInt16(milliseconds)...)
+	hour := byte(t.Hour())
+	if summerTime(t) {
+		hour |= 0x80
+	}
+	data = append(data,
+		byte(t.Minute()),
+		hour,
+		byte(t.Day())|byte(weekday)<<5,
+		byte(t.Month()),
+		byte(t.Year()-2000))
+	return data
 }
@@ -1,14 +1,17 @@
 package iec104
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestASDU_ParseASDU(t *testing.T) {
 	type fields struct {
 		TypeID        byte
 		Length        byte
-		Cause         uint16
+		Cause         Cause
 		PublicAddress uint16
 	}
 	type args struct {
@@ -28,6 +31,12 @@ func TestASDU_ParseASDU(t *testing.T) {
 		{"测试连续单点遥测(MMeNa1)，sq=true,type_id=9", fields{}, args{asduBytes: []byte{0x09, 0xBC, 0x14, 0x00, 0x01, 0x00, 0x51, 0x40, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0xF4, 0x00, 0x00, 0xF4, 0x00, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0xF4, 0x00, 0x00, 0xF4, 0x00, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0xF4, 0x00, 0x00, 0xF4, 0x00, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0xF4, 0x00, 0x00, 0xF4, 0x00, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0xF4, 0x00, 0x00, 0xF4, 0x00, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0xF4, 0x00, 0x00, 0xF4, 0x00, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0xF4, 0x00, 0x00, 0xF4, 0x00, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0xF4, 0x00, 0x00, 0xF4, 0x00, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0xF4, 0x00, 0x00, 0xF4, 0x00, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00, 0xF4, 0x00, 0x00, 0xF4, 0x00, 0x00, 0x50, 0x5F, 0x00}}, false},
 		{"测试连续带品质描述的浮点值(MMeNc1)，sq=true,type_id=13", fields{}, args{asduBytes: []byte{0x0d, 0xb0, 0x14, 0x00, 0x01, 0x00, 0x61, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x52, 0x3f, 0x00, 0x00, 0x80, 0xac, 0x3f, 0x00, 0x00, 0x00, 0xb4, 0x3f, 0x00, 0x9a, 0x99, 0x19, 0x3a, 0x00, 0x9a, 0x99, 0x19, 0x3a, 0x00, 0x9a, 0x99, 0x19, 0x30, 0x0, 0x00, 0x00, 0x70, 0x3d, 0x00, 0x00, 0x00, 0x70, 0x3d, 0x00, 0x00, 0x60, 0x1f, 0x41, 0x00, 0x00, 0x00, 0x2f, 0x41, 0x00, 0x00, 0x60, 0x01, 0x3f, 0x00, 0x00, 0x00, 0xcf, 0x3d, 0x00, 0x00, 0x40, 0x1b, 0x3f, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x41, 0x00, 0x00, 0x00, 0x00, 0x41, 0x00, 0x00, 0x80, 0x3b, 0x40, 0x00, 0x00, 0x40, 0x03, 0x40, 0x00, 0x00, 0xc0, 0x28, 0x40, 0x00, 0x9a, 0x99, 0x19, 0x3a, 0x00, 0x9a, 0x99, 0x19, 0x3a, 0x00, 0x9a, 0x99, 0x19, 0x3a, 0x00, 0x00, 0x00, 0x70, 0x3d, 0x00, 0x00, 0x00, 0x70, 0x3d, 0x00, 0x00, 0x60, 0x38, 0x41, 0x00, 0x00, 0xa0, 0x25, 0x41, 0x00, 0x00, 0xe0, 0x68, 0x3f, 0x00, 0x00, 0x40, 0x1b, 0x3f, 0x00, 0x00, 0x40, 0x9b, 0x3e, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}}, false},
 		{"测试连续遥脉电度值(MItNa1)，sq=true,type_id=15", fields{}, args{asduBytes: []byte{0x0f, 0xb0, 0x25, 0x00, 0x01, 0x00, 0x01, 0x64, 0x00, 0xfe, 0x12, 0x00, 0x00, 0x03, 0x68, 0x03, 0x00, 0x00, 0x03, 0xe4, 0x11, 0x00, 0x00, 0x03, 0x0f, 0x06, 0x00, 0x00, 0x03, 0xfe, 0x12, 0x00, 0x00, 0x03, 0x69, 0x03, 0x00, 0x00, 0x03, 0xe4, 0x11, 0x00, 0x00, 0x03, 0x10, 0x06, 0x00, 0x00, 0x03, 0xeb, 0x1a, 0x00, 0x00, 0x03, 0xce, 0x04, 0x00, 0x00, 0x03, 0xf6, 0x18, 0x00, 0x00, 0x03, 0x4a, 0x04, 0x00, 0x00, 0x03, 0xec, 0x1a, 0x00, 0x00, 0x03, 0xd1, 0x04, 0x00, 0x00, 0x03, 0xfa, 0x18, 0x00, 0x00, 0x03, 0xf8, 0x08, 0x00, 0x00, 0x03, 0x8a, 0x1b, 0x00, 0x00, 0x03, 0x17, 0x03, 0x00, 0x00, 0x03, 0xc9, 0x27, 0x00, 0x00, 0x03, 0xc8, 0x02, 0x00, 0x00, 0x03, 0xc3, 0x18, 0x00, 0x00, 0x03, 0x17, 0x03, 0x00, 0x00, 0x03, 0xcf, 0x27, 0x00, 0x00, 0x03, 0xc8, 0x02, 0x00, 0x00, 0x03, 0xb5, 0x1b, 0x00, 0x00, 0x03, 0x16, 0x03, 0x00, 0x00, 0x03, 0xef, 0x2d, 0x00, 0x00, 0x03, 0xc8, 0x02, 0x00, 0x00, 0x03, 0xb6, 0x1b, 0x00, 0x00, 0x03, 0x16, 0x03, 0x00, 0x00, 0x03, 0xf1, 0x2d, 0x00, 0x00, 0x03, 0xc8, 0x02, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x83, 0x00, 0x00, 0x00, 0x00, 0x83, 0x00, 0x00, 0x00, 0x00, 0x83, 0x00, 0x00, 0x00, 0x00, 0x83, 0x00, 0x00, 0x00, 0x00, 0x83, 0x00, 0x00, 0x00, 0x00, 0x83, 0x00, 0x00, 0x00, 0x00, 0x83, 0x00, 0x00, 0x00, 0x00, 0x83, 0x00, 0x00, 0x00, 0x00, 0x83, 0x00, 0x00, 0x00, 0x00, 0x83, 0x00, 0x00, 0x00, 0x00, 0x83}}, false},
+		{"测试不连续遥脉电度值(MItNa1)，sq=false,type_id=15", fields{}, args{asduBytes: []byte{0x0f, 0x02, 0x25, 0x00, 0x01, 0x00, 0x01, 0x64, 0x00, 0xfe, 0x12, 0x00, 0x00, 0x21, 0x02, 0x64, 0x00, 0xff, 0xff, 0xff, 0xff, 0x42}}, false},
+		{"测试带时标的遥脉电度值(MItTb1)，sq=false,type_id=37", fields{}, args{asduBytes: []byte{0x25, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x64, 0x00, 0xfe, 0x12, 0x00, 0x00, 0x21, 0xD3, 0x42, 0x3B, 0x0E, 0x06, 0x0B, 0x13}}, false},
+		{"测试带变位检出的成组单点信息(MPsNa1)，sq=false,type_id=20", fields{}, args{asduBytes: []byte{0x14, 0x02, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x80, 0x00, 0x80, 0x00, 0x02, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0x80}}, false},
+		{"测试连续带变位检出的成组单点信息(MPsNa1)，sq=true,type_id=20", fields{}, args{asduBytes: []byte{0x14, 0x82, 0x14, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x80, 0x00, 0x80, 0x00, 0xff, 0xff, 0xff, 0xff, 0x80}}, false},
+		{"测试信息对象数量超过报文长度(MMeNc1)，sq=false,type_id=13", fields{}, args{asduBytes: []byte{0x0d, 0x02, 0x03, 0x00, 0x01, 0x00, 0x01, 0x40, 0x00, 0x00, 0x00, 0x80, 0x3f, 0x00}}, true},
+		{"测试信息对象数量超过报文长度(MMeNa1)，sq=true,type_id=9", fields{}, args{asduBytes: []byte{0x09, 0x83, 0x14, 0x00, 0x01, 0x00, 0x01, 0x40, 0x00, 0x50, 0x5F, 0x00, 0x50, 0x5F, 0x00}}, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -48,7 +57,7 @@ func TestASDU_ParseTime(t *testing.T) {
 	type fields struct {
 		TypeID        byte
 		Length        byte
-		Cause         uint16
+		Cause         Cause
 		PublicAddress uint16
 		Ts            float64
 	}
@@ -79,12 +88,41 @@ func TestASDU_ParseTime(t *testing.T) {
 	}
 }
 
+func TestASDU_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		asduBytes []byte
+		want      string
+	}{
+		{"测试带时标的短浮点数(MMeTf1)", []byte{0x24, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x40, 0x00, 0x00, 0x00, 0xC0, 0x3F, 0x10, 0xD3, 0x42, 0x3B, 0x0E, 0x06, 0x0B, 0x13},
+			`{"type_id":36,"type_name":"M_ME_TF_1","sequence":false,"cause":3,"public_address":1,"signals":[{"type_id":36,"address":16385,"value":1.5,"quality":16,"ts":1573023557.107,"cause":3}]}`},
+		{"测试未解析信息对象", nil, `{"type_id":0,"type_name":"TypeID(0)","sequence":false,"cause":0,"public_address":0,"signals":[]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asdu := new(ASDU)
+			if tt.asduBytes != nil {
+				if _, err := asdu.ParseASDU(tt.asduBytes); err != nil {
+					t.Fatalf("ASDU.ParseASDU() error = %v", err)
+				}
+			}
+			got, err := json.Marshal(asdu)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("json.Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestASDU_ParseVariable(t *testing.T) {
 	type fields struct {
 		TypeID        byte
 		Sequence      bool
 		Length        byte
-		Cause         uint16
+		Cause         Cause
 		PublicAddress uint16
 		Ts            float64
 	}
@@ -122,3 +160,262 @@ func TestASDU_ParseVariable(t *testing.T) {
 		})
 	}
 }
+
+func Test_encodeCP56Time2a(t *testing.T) {
+	tests := []struct {
+		name string
+		t    time.Time
+		want []byte
+	}{
+		{"测试7字节时间编码", time.Date(2019, 11, 6, 14, 59, 17, 107000000, time.Local), []byte{0xD3, 0x42, 0x3B, 0x0E, 0x66, 0x0B, 0x13}},
+		{"测试星期日编码为7", time.Date(2021, 1, 3, 0, 0, 0, 0, time.Local), []byte{0x00, 0x00, 0x00, 0x00, 0xE3, 0x01, 0x15}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeCP56Time2a(tt.t); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encodeCP56Time2a() = [% X], want [% X]", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestASDU_ParseASDUOverflow(t *testing.T) {
+	//连续4个标度化值,仅第3个信息对象品质描述词的OV位置位
+	asduBytes := []byte{0x0b, 0x84, 0x03, 0x00, 0x01, 0x00, 0x01, 0x40, 0x00,
+		0x64, 0x00, 0x00,
+		0x9c, 0xff, 0x00,
+		0xff, 0x7f, 0x01,
+		0x00, 0x00, 0x00}
+	signals, err := new(ASDU).ParseASDU(asduBytes)
+	if err != nil {
+		t.Fatalf("ASDU.ParseASDU() error = %v", err)
+	}
+	wantValues := []float64{100, -100, 32767, 0}
+	if len(signals) != len(wantValues) {
+		t.Fatalf("ASDU.ParseASDU() got %d signals, want %d", len(signals), len(wantValues))
+	}
+	for i, s := range signals {
+		if s.Address != 0x4001+uint32(i) || s.Value != wantValues[i] {
+			t.Errorf("signals[%d] = %+v, want address %#x value %v", i, *s, 0x4001+i, wantValues[i])
+		}
+		if s.Overflow() != (i == 2) {
+			t.Errorf("signals[%d].Overflow() = %v, want %v", i, s.Overflow(), i == 2)
+		}
+	}
+}
+
+func TestSupportedTypeIDs(t *testing.T) {
+	infos := SupportedTypeIDs()
+	for i, info := range infos {
+		if i > 0 && infos[i-1].TypeID >= info.TypeID {
+			t.Errorf("SupportedTypeIDs() 未按类型标识排序: %d, %d", infos[i-1].TypeID, info.TypeID)
+		}
+		//每个登记的类型都能被解析器解析
+		asduBytes := append([]byte{info.TypeID, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00}, make([]byte, info.ElementSize)...)
+		if _, err := new(ASDU).ParseASDU(asduBytes); err != nil {
+			t.Errorf("ASDU.ParseASDU() 类型%s error = %v", info.Name, err)
+		}
+	}
+}
+
+func Test_parseCP56Time2a(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("加载时区失败: %v", err)
+	}
+	//夏令时结束时01:30重复出现,先为EDT后为EST
+	edt := time.Date(2021, 11, 7, 5, 30, 0, 0, time.UTC).In(loc)
+	est := time.Date(2021, 11, 7, 6, 30, 0, 0, time.UTC).In(loc)
+	tests := []struct {
+		name       string
+		t          time.Time
+		wantSummer bool
+	}{
+		{"测试夏令时", time.Date(2021, 7, 1, 12, 0, 0, 0, loc), true},
+		{"测试标准时间", time.Date(2021, 1, 1, 12, 0, 0, 0, loc), false},
+		{"测试重复一小时中的夏令时", edt, true},
+		{"测试重复一小时中的标准时间", est, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := encodeCP56Time2a(tt.t)
+			if su := b[3]&0x80 != 0; su != tt.wantSummer {
+				t.Errorf("encodeCP56Time2a() SU = %v, want %v", su, tt.wantSummer)
+			}
+			got, summer := parseCP56Time2a(b, loc)
+			if !got.Equal(tt.t) || summer != tt.wantSummer {
+				t.Errorf("parseCP56Time2a() = %v, %v, want %v, %v", got, summer, tt.t, tt.wantSummer)
+			}
+		})
+	}
+}
+
+func TestASDU_ParseASDUTestBit(t *testing.T) {
+	asdu := new(ASDU)
+	if _, err := asdu.ParseASDU([]byte{0x01, 0x01, 0x83, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01}); err != nil {
+		t.Fatalf("ASDU.ParseASDU() error = %v", err)
+	}
+	if !asdu.Test || asdu.Cause != 3 || asdu.Negative {
+		t.Errorf("ASDU.ParseASDU() Test = %v, Cause = %d, Negative = %v, want true, 3, false", asdu.Test, asdu.Cause, asdu.Negative)
+	}
+}
+
+func TestLenientParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		asduBytes   []byte
+		wantSignals int
+		wantOffset  int
+	}{
+		{"测试第三个遥测不完整", []byte{0x09, 0x03, 0x03, 0x00, 0x01, 0x00,
+			0x01, 0x00, 0x00, 0x10, 0x00, 0x00, 0x02, 0x00, 0x00, 0x20, 0x00, 0x00, 0x03, 0x00}, 2, 18},
+		{"测试连续遥信不完整", []byte{0x01, 0x83, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01}, 1, 10},
+		{"测试只有地址", []byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00}, 0, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := new(ASDU).ParseASDU(tt.asduBytes); err == nil {
+				t.Fatal("ASDU.ParseASDU() 非宽松解析时 error = nil")
+			}
+			LenientParse = true
+			defer func() { LenientParse = false }()
+			asdu := new(ASDU)
+			signals, err := asdu.ParseASDU(tt.asduBytes)
+			if err != nil {
+				t.Fatalf("ASDU.ParseASDU() error = %v", err)
+			}
+			if len(signals) != tt.wantSignals {
+				t.Errorf("ASDU.ParseASDU() 解析%d个信息对象, want %d", len(signals), tt.wantSignals)
+			}
+			if e := asdu.ObjectError; e == nil || e.Index != tt.wantSignals || e.Offset != tt.wantOffset {
+				t.Errorf("ASDU.ObjectError = %+v, want index %d offset %d", e, tt.wantSignals, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestParseASDUStepPosition(t *testing.T) {
+	tests := []struct {
+		name      string
+		asduBytes []byte
+		wantValue float64
+	}{
+		{"测试步位置", []byte{0x05, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0xff, 0x00}, -1},
+		{"测试连续步位置", []byte{0x05, 0x81, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0xff, 0x00}, -1},
+		{"测试带时标的步位置", []byte{0x20, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0xff, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x15}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signals, err := new(ASDU).ParseASDU(tt.asduBytes)
+			if err != nil {
+				t.Fatalf("ASDU.ParseASDU() error = %v", err)
+			}
+			s := signals[0]
+			if p, ok := s.Data.(*StepPosition); s.Value != tt.wantValue || !ok || !p.Transient || s.Address != 1 {
+				t.Errorf("ASDU.ParseASDU() = %+v, data = %+v, want value %v transient", s, s.Data, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestParseDirectory(t *testing.T) {
+	//两个文件,第二个为最后一个文件
+	asduBytes := []byte{0x7e, 0x82, 0x0d, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00,
+		0x01, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x15,
+		0x02, 0x00, 0x34, 0x12, 0x01, 0x20, 0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x15}
+	apdu := &APDU{ASDU: new(ASDU)}
+	signals, err := apdu.ASDU.ParseASDU(asduBytes)
+	if err != nil {
+		t.Fatalf("ASDU.ParseASDU() error = %v", err)
+	}
+	apdu.Signals = signals
+	files := ParseDirectory(apdu)
+	if len(files) != 2 {
+		t.Fatalf("ParseDirectory() = %+v, want 2个文件", files)
+	}
+	want := time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local)
+	if f := files[0]; f.Address != 1 || f.Name != 1 || f.Length != 4096 || f.LastFile() || !f.Time.Equal(want) {
+		t.Errorf("ParseDirectory()[0] = %+v", f)
+	}
+	if f := files[1]; f.Address != 2 || f.Name != 2 || f.Length != 0x011234 || !f.LastFile() {
+		t.Errorf("ParseDirectory()[1] = %+v", f)
+	}
+	if files := ParseDirectory(&APDU{}); files != nil {
+		t.Errorf("ParseDirectory() = %+v, want nil", files)
+	}
+}
+
+func TestParseASDUQDS(t *testing.T) {
+	header := func(typeID byte) []byte {
+		return []byte{typeID, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00}
+	}
+	ts := []byte{0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x15}
+	tests := []struct {
+		name      string
+		asduBytes []byte
+		wantValue float64
+		wantTs    bool
+	}{
+		{"测试归一化值", append(header(MMeNa1), 0x34, 0x12, 0xf1), 0x1234, false},
+		{"测试标度化值", append(header(MMeNb1), 0xff, 0xff, 0xf1), -1, false},
+		{"测试短浮点数", append(header(MMeNc1), 0x00, 0x00, 0x80, 0x3f, 0xf1), 1, false},
+		{"测试带时标的归一化值", append(append(header(MMeTd1), 0x34, 0x12, 0xf1), ts...), 0x1234, true},
+		{"测试带时标的标度化值", append(append(header(MMeTe1), 0xff, 0xff, 0xf1), ts...), -1, true},
+		{"测试带时标的短浮点数", append(append(header(MMeTf1), 0x00, 0x00, 0x80, 0x3f, 0xf1), ts...), 1, true},
+	}
+	want := QDS{Overflow: true, Blocked: true, Substituted: true, NotTopical: true, Invalid: true}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signals, err := new(ASDU).ParseASDU(tt.asduBytes)
+			if err != nil {
+				t.Fatalf("ASDU.ParseASDU() error = %v", err)
+			}
+			s := signals[0]
+			if s.Value != tt.wantValue || s.Address != 1 || (s.Ts != 0) != tt.wantTs {
+				t.Errorf("ASDU.ParseASDU() = %+v, want value %v", s, tt.wantValue)
+			}
+			if got := s.QDS(); got != want {
+				t.Errorf("Signal.QDS() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseASDUNormalizedWithoutQuality(t *testing.T) {
+	tests := []struct {
+		name        string
+		asduBytes   []byte
+		wantAddress []uint32
+		wantValue   []float64
+	}{
+		{"测试连续的3个遥测值", []byte{MMeNd1, 0x83, 0x01, 0x00, 0x01, 0x00, 0x01, 0x40, 0x00, 0x34, 0x12, 0x00, 0x40, 0xff, 0xff},
+			[]uint32{0x4001, 0x4002, 0x4003}, []float64{0x1234, 0x4000, 0xffff}},
+		{"测试不连续的2个遥测值", []byte{MMeNd1, 0x02, 0x01, 0x00, 0x01, 0x00, 0x01, 0x40, 0x00, 0x34, 0x12, 0x05, 0x40, 0x00, 0x01, 0x00},
+			[]uint32{0x4001, 0x4005}, []float64{0x1234, 0x0001}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signals, err := new(ASDU).ParseASDU(tt.asduBytes)
+			if err != nil {
+				t.Fatalf("ASDU.ParseASDU() error = %v", err)
+			}
+			if len(signals) != len(tt.wantValue) {
+				t.Fatalf("ASDU.ParseASDU() got %d signals, want %d", len(signals), len(tt.wantValue))
+			}
+			for i, s := range signals {
+				if s.Address != tt.wantAddress[i] || s.Value != tt.wantValue[i] || s.Quality != 0 {
+					t.Errorf("signals[%d] = %+v, want address %#x value %v 品质有效", i, s, tt.wantAddress[i], tt.wantValue[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseASDUUnsupportedType(t *testing.T) {
+	//默认模式下不支持的类型标识同样返回error,不退出进程
+	asdu := []byte{0x7c, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00}
+	if _, err := new(ASDU).ParseASDU(asdu); err == nil {
+		t.Error("ASDU.ParseASDU() 不支持的类型标识未返回error")
+	}
+}
@@ -0,0 +1,170 @@
+package iec104
+
+import (
+	"errors"
+	"sort"
+)
+
+//maxASDULen ASDU长度的最大值,即APDU最大长度减去4个字节控制域
+const maxASDULen = maxAPDULen - 4
+
+//ErrBatchSelect 批量命令不支持选择后执行
+var ErrBatchSelect = errors.New("批量命令不支持选择后执行")
+
+//batchKey 可合并到同一ASDU的命令:类型标识和公共地址相同
+type batchKey struct {
+	typeID     byte
+	commonAddr uint16
+}
+
+//SendCommands 批量发送直接执行的控制命令,类型标识和公共地址相同的命令合并到同一ASDU,
+//信息对象地址连续时使用SQ=1,超过帧长度时拆分为多帧。返回与cmds一一对应的结果,
+//各命令的确认与SendCommand相同,Select为true的命令返回ErrBatchSelect
+func (c *Client) SendCommands(cmds []Command) []error {
+	results := make([]error, len(cmds))
+	if !c.connected() {
+		for i := range results {
+			results[i] = ErrNotConnected
+		}
+		return results
+	}
+	groups := make(map[batchKey][]int)
+	order := make([]batchKey, 0)
+	seen := make(map[pointKey]bool)
+	for i, cmd := range cmds {
+		key := pointKey{cmd.CommonAddr, cmd.IOA}
+		switch {
+		case len(cmd.Value) == 0 && cmd.Time.IsZero():
+			results[i] = errors.New("命令信息元素为空")
+		case cmd.Select:
+			results[i] = ErrBatchSelect
+		case seen[key]:
+			results[i] = errors.New("同一信息对象的命令重复")
+		default:
+			seen[key] = true
+			k := batchKey{cmd.TypeID, cmd.CommonAddr}
+			if _, ok := groups[k]; !ok {
+				order = append(order, k)
+			}
+			groups[k] = append(groups[k], i)
+		}
+	}
+	for _, k := range order {
+		indexes := groups[k]
+		sort.Slice(indexes, func(i, j int) bool { return cmds[indexes[i]].IOA < cmds[indexes[j]].IOA })
+		for _, chunk := range splitBatch(cmds, indexes) {
+			c.sendBatch(cmds, chunk, results)
+		}
+	}
+	return results
+}
+
+//splitBatch 按帧长度拆分同一类型标识和公共地址的命令,每帧最多127个信息对象
+func splitBatch(cmds []Command, indexes []int) [][]int {
+	chunks := make([][]int, 0)
+	chunk := make([]int, 0)
+	size := 6
+	for _, i := range indexes {
+		n := 3 + len(encodeCommandValue(cmds[i], CommandExecuting))
+		if len(chunk) > 0 && (size+n > maxASDULen || len(chunk) == 127) {
+			chunks = append(chunks, chunk)
+			chunk = make([]int, 0)
+			size = 6
+		}
+		chunk = append(chunk, i)
+		size += n
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+//sendBatch 将一组命令编码到一个ASDU发送,等待各命令的激活确认,结果写入results
+func (c *Client) sendBatch(cmds []Command, chunk []int, results []error) {
+	first := cmds[chunk[0]]
+	timeout := c.timeoutFor(first)
+	if err := c.waitCommandToken(timeout); err != nil {
+		for _, i := range chunk {
+			results[i] = err
+		}
+		return
+	}
+	type batchItem struct {
+		index int
+		key   pointKey
+		p     *pendingCommand
+	}
+	items := make([]batchItem, 0, len(chunk))
+	now := c.clock().Now()
+	for _, i := range chunk {
+		key := pointKey{cmds[i].CommonAddr, cmds[i].IOA}
+		l, err := c.lockPoint(key, timeout)
+		if err != nil {
+			results[i] = err
+			continue
+		}
+		p := &pendingCommand{
+			cmd:    cmds[i],
+			lock:   l,
+			state:  CommandExecuting,
+			value:  encodeCommandValue(cmds[i], CommandExecuting),
+			sentAt: now,
+			result: make(chan error, 1),
+			deact:  make(chan error, 1),
+		}
+		items = append(items, batchItem{i, key, p})
+	}
+	if len(items) == 0 {
+		return
+	}
+	sequence := sequenceTypeIDs[first.TypeID] && first.Time.IsZero()
+	for j := 1; j < len(items) && sequence; j++ {
+		sequence = items[j].key.ioa == items[j-1].key.ioa+1
+	}
+	vsq := byte(len(items))
+	if sequence {
+		vsq |= 0x80
+	}
+	data := []byte{first.TypeID, vsq, c.cause(CauseActivation), c.Config.Originator}
+	data = append(data, encodeCommonAddr(first.CommonAddr)...)
+	c.mu.Lock()
+	for j, item := range items {
+		if !sequence || j == 0 {
+			data = append(data, encodeIOA(item.key.ioa)...)
+		}
+		data = append(data, item.p.value...)
+		c.pending[item.key] = item.p
+	}
+	c.mu.Unlock()
+	frame, err := c.sendIFrameTimeout(data, timeout)
+	if err != nil {
+		for _, item := range items {
+			c.removePending(item.key, item.p)
+			results[item.index] = err
+		}
+		return
+	}
+	c.Logger.Debugf("发送批量命令,类型:%s,公共地址:%d,信息对象数量:%d: [% X]", TypeIDName(first.TypeID), first.CommonAddr, len(items), frame)
+	deadline := c.clock().After(timeout)
+	expired := false
+	for _, item := range items {
+		if !expired {
+			select {
+			case err := <-item.p.result:
+				results[item.index] = err
+				continue
+			case <-deadline:
+				expired = true
+			}
+		}
+		//超时后只取已收到的确认
+		select {
+		case err := <-item.p.result:
+			results[item.index] = err
+		default:
+			c.removePending(item.key, item.p)
+			results[item.index] = ErrCommandTimeout
+		}
+	}
+}
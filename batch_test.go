@@ -0,0 +1,55 @@
+package iec104
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_splitBatch(t *testing.T) {
+	cmds := make([]Command, 100)
+	indexes := make([]int, len(cmds))
+	for i := range cmds {
+		cmds[i] = Command{TypeID: CSeNc1, CommonAddr: 1, IOA: uint32(2 * i), Value: make([]byte, 5)}
+		indexes[i] = i
+	}
+	//每个信息对象8个字节,每帧最多(249-6)/8=30个
+	got := make([]int, 0)
+	for _, chunk := range splitBatch(cmds, indexes) {
+		got = append(got, len(chunk))
+	}
+	if want := []int{30, 30, 30, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("splitBatch() = %v, want %v", got, want)
+	}
+}
+
+func TestClient_SendCommands(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	cmds := []Command{
+		{TypeID: CScNa1, CommonAddr: 1, IOA: 2, Value: []byte{0x01}},
+		{TypeID: CScNa1, CommonAddr: 1, IOA: 1, Value: []byte{0x01}},
+		{TypeID: CScNa1, CommonAddr: 1, IOA: 3, Value: []byte{0x00}},
+		{TypeID: CScNa1, CommonAddr: 1, IOA: 4, Value: []byte{0x01}, Select: true},
+	}
+	res := make(chan []error, 1)
+	go func() {
+		res <- c.SendCommands(cmds)
+	}()
+	frame := s.nextIFrame(t, CScNa1)
+	want := []byte{CScNa1, 0x83, 0x06, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x01, 0x00}
+	if !reflect.DeepEqual(frame[6:], want) {
+		t.Fatalf("批量命令ASDU = [% X], want [% X]", frame[6:], want)
+	}
+	asdu := append([]byte{}, frame[6:]...)
+	asdu[2] = 7
+	s.SendIFrame(asdu)
+	select {
+	case errs := <-res:
+		if !reflect.DeepEqual(errs, []error{nil, nil, nil, ErrBatchSelect}) {
+			t.Errorf("Client.SendCommands() = %v", errs)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("收到激活确认后SendCommands未返回")
+	}
+}
@@ -0,0 +1,132 @@
+package iec104
+
+import (
+	"fmt"
+	"strconv"
+)
+
+//Cause 传输原因COT,ASDU第3个字节的低6位
+type Cause uint16
+
+//传输原因,14~19、42~43为保留,48~63为专用范围
+const (
+	CausePeriodic                Cause = 1  //周期、循环
+	CauseBackground              Cause = 2  //背景扫描
+	CauseSpontaneous             Cause = 3  //突发(自发)
+	CauseInitialized             Cause = 4  //初始化
+	CauseRequest                 Cause = 5  //请求或被请求
+	CauseActivation              Cause = 6  //激活
+	CauseActivationCon           Cause = 7  //激活确认
+	CauseDeactivation            Cause = 8  //停止激活
+	CauseDeactivationCon         Cause = 9  //停止激活确认
+	CauseActivationTermination   Cause = 10 //激活终止
+	CauseReturnRemote            Cause = 11 //远方命令引起的返送信息
+	CauseReturnLocal             Cause = 12 //当地命令引起的返送信息
+	CauseFileTransfer            Cause = 13 //文件传输
+	CauseInterrogatedByStation   Cause = 20 //响应站召唤
+	CauseInterrogatedByGroup1    Cause = 21 //响应第1组召唤
+	CauseInterrogatedByGroup2    Cause = 22 //响应第2组召唤
+	CauseInterrogatedByGroup3    Cause = 23 //响应第3组召唤
+	CauseInterrogatedByGroup4    Cause = 24 //响应第4组召唤
+	CauseInterrogatedByGroup5    Cause = 25 //响应第5组召唤
+	CauseInterrogatedByGroup6    Cause = 26 //响应第6组召唤
+	CauseInterrogatedByGroup7    Cause = 27 //响应第7组召唤
+	CauseInterrogatedByGroup8    Cause = 28 //响应第8组召唤
+	CauseInterrogatedByGroup9    Cause = 29 //响应第9组召唤
+	CauseInterrogatedByGroup10   Cause = 30 //响应第10组召唤
+	CauseInterrogatedByGroup11   Cause = 31 //响应第11组召唤
+	CauseInterrogatedByGroup12   Cause = 32 //响应第12组召唤
+	CauseInterrogatedByGroup13   Cause = 33 //响应第13组召唤
+	CauseInterrogatedByGroup14   Cause = 34 //响应第14组召唤
+	CauseInterrogatedByGroup15   Cause = 35 //响应第15组召唤
+	CauseInterrogatedByGroup16   Cause = 36 //响应第16组召唤
+	CauseRequestByGeneralCounter Cause = 37 //响应计数量站召唤
+	CauseRequestByGroup1Counter  Cause = 38 //响应第1组计数量召唤
+	CauseRequestByGroup2Counter  Cause = 39 //响应第2组计数量召唤
+	CauseRequestByGroup3Counter  Cause = 40 //响应第3组计数量召唤
+	CauseRequestByGroup4Counter  Cause = 41 //响应第4组计数量召唤
+	CauseUnknownTypeID           Cause = 44 //未知的类型标识
+	CauseUnknownCause            Cause = 45 //未知的传输原因
+	CauseUnknownCommonAddr       Cause = 46 //未知的应用服务数据单元公共地址
+	CauseUnknownIOA              Cause = 47 //未知的信息对象地址
+)
+
+//causeNames 传输原因名称,不含第n组召唤
+var causeNames = map[Cause]string{
+	CausePeriodic:                "周期",
+	CauseBackground:              "背景扫描",
+	CauseSpontaneous:             "突发",
+	CauseInitialized:             "初始化",
+	CauseRequest:                 "请求",
+	CauseActivation:              "激活",
+	CauseActivationCon:           "激活确认",
+	CauseDeactivation:            "停止激活",
+	CauseDeactivationCon:         "停止激活确认",
+	CauseActivationTermination:   "激活终止",
+	CauseReturnRemote:            "远方命令返送",
+	CauseReturnLocal:             "当地命令返送",
+	CauseFileTransfer:            "文件传输",
+	CauseInterrogatedByStation:   "响应站召唤",
+	CauseRequestByGeneralCounter: "响应计数量站召唤",
+	CauseUnknownTypeID:           "未知的类型标识",
+	CauseUnknownCause:            "未知的传输原因",
+	CauseUnknownCommonAddr:       "未知的公共地址",
+	CauseUnknownIOA:              "未知的信息对象地址",
+}
+
+//String 传输原因名称,保留和专用范围的传输原因返回数字
+func (c Cause) String() string {
+	if name, ok := causeNames[c]; ok {
+		return name
+	}
+	switch {
+	case c >= CauseInterrogatedByGroup1 && c <= CauseInterrogatedByGroup16:
+		return fmt.Sprintf("响应第%d组召唤", c-CauseInterrogatedByStation)
+	case c >= CauseRequestByGroup1Counter && c <= CauseRequestByGroup4Counter:
+		return fmt.Sprintf("响应第%d组计数量召唤", c-CauseRequestByGeneralCounter)
+	}
+	return strconv.Itoa(int(c))
+}
+
+//Valid 是否为标准定义的传输原因,保留(0、14~19、42~43)和专用范围(48~63)返回false
+func (c Cause) Valid() bool {
+	return c >= CausePeriodic && c <= CauseFileTransfer ||
+		c >= CauseInterrogatedByStation && c <= CauseRequestByGroup4Counter ||
+		c >= CauseUnknownTypeID && c <= CauseUnknownIOA
+}
+
+//sendCauses 控制方向类型标识允许发送的传输原因,未列出的类型为激活和停止激活
+var sendCauses = map[byte][]Cause{
+	CRdNa1: {CauseRequest},
+	FScNa1: {CauseFileTransfer},
+	CIcNa1: {CauseActivation, CauseDeactivation},
+	CCiNa1: {CauseActivation, CauseDeactivation},
+	CCsNa1: {CauseActivation},
+	CRpNa1: {CauseActivation},
+}
+
+//checkSendCause 校验发送的ASDU的传输原因与类型标识的方向是否相符,
+//命令只能以激活或停止激活发送,读命令为请求,文件传输为文件传输
+func checkSendCause(asdu []byte) error {
+	if len(asdu) < 3 {
+		return nil
+	}
+	typeID, cause := asdu[0], Cause(asdu[2]&0x3f)
+	info, ok := typeIDs[typeID]
+	if !ok {
+		return nil
+	}
+	if !info.Control {
+		return fmt.Errorf("监视方向类型[%s]不能由客户端发送", info.Name)
+	}
+	allowed, ok := sendCauses[typeID]
+	if !ok {
+		allowed = []Cause{CauseActivation, CauseDeactivation}
+	}
+	for _, c := range allowed {
+		if c == cause {
+			return nil
+		}
+	}
+	return fmt.Errorf("类型[%s]不能以传输原因[%v]发送", info.Name, cause)
+}
@@ -0,0 +1,59 @@
+package iec104
+
+import "testing"
+
+func TestCause(t *testing.T) {
+	tests := []struct {
+		name      string
+		c         Cause
+		wantName  string
+		wantValid bool
+	}{
+		{"测试激活确认", CauseActivationCon, "激活确认", true},
+		{"测试第3组召唤", CauseInterrogatedByGroup3, "响应第3组召唤", true},
+		{"测试第4组计数量召唤", CauseRequestByGroup4Counter, "响应第4组计数量召唤", true},
+		{"测试未知的信息对象地址", CauseUnknownIOA, "未知的信息对象地址", true},
+		{"测试保留值0", 0, "0", false},
+		{"测试保留值14", 14, "14", false},
+		{"测试保留值42", 42, "42", false},
+		{"测试专用范围", 48, "48", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.String(); got != tt.wantName {
+				t.Errorf("Cause.String() = %v, want %v", got, tt.wantName)
+			}
+			if got := tt.c.Valid(); got != tt.wantValid {
+				t.Errorf("Cause.Valid() = %v, want %v", got, tt.wantValid)
+			}
+		})
+	}
+}
+
+func Test_checkSendCause(t *testing.T) {
+	tests := []struct {
+		name    string
+		asdu    []byte
+		wantErr bool
+	}{
+		{"测试单点命令激活", encodeCommandASDU(CScNa1, byte(CauseActivation), 0, 1, 1, []byte{1}), false},
+		{"测试单点命令停止激活", encodeCommandASDU(CScNa1, byte(CauseDeactivation), 0, 1, 1, []byte{1}), false},
+		{"测试试验位", encodeCommandASDU(CScNa1, byte(CauseActivation)|testBit, 0, 1, 1, []byte{1}), false},
+		{"测试单点命令突发", encodeCommandASDU(CScNa1, byte(CauseSpontaneous), 0, 1, 1, []byte{1}), true},
+		{"测试读命令请求", encodeCommandASDU(CRdNa1, byte(CauseRequest), 0, 1, 1, nil), false},
+		{"测试读命令激活", encodeCommandASDU(CRdNa1, byte(CauseActivation), 0, 1, 1, nil), true},
+		{"测试总召唤停止激活", encodeCommandASDU(CIcNa1, byte(CauseDeactivation), 0, 1, 0, []byte{20}), false},
+		{"测试计数量召唤停止激活", encodeCommandASDU(CCiNa1, byte(CauseDeactivation), 0, 1, 0, []byte{5}), false},
+		{"测试总召唤请求", encodeCommandASDU(CIcNa1, byte(CauseRequest), 0, 1, 0, []byte{20}), true},
+		{"测试时钟同步停止激活", encodeCommandASDU(CCsNa1, byte(CauseDeactivation), 0, 1, 0, nil), true},
+		{"测试召唤目录", encodeCommandASDU(FScNa1, byte(CauseFileTransfer), 0, 1, 0, []byte{0, 0, 0, 0}), false},
+		{"测试监视方向类型", encodeCommandASDU(MSpNa1, byte(CauseSpontaneous), 0, 1, 1, []byte{1}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkSendCause(tt.asdu); (err != nil) != tt.wantErr {
+				t.Errorf("checkSendCause() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
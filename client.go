@@ -5,6 +5,7 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"runtime/debug"
 	"sync"
 	"syscall"
 	"time"
@@ -13,28 +14,84 @@ import (
 )
 
 var (
-	contextTimeout    = 30 * time.Second
-	dialTimeout       = 5 * time.Second
-	testInterval      = 20 * time.Second
-	totalCallInterval = 15 * time.Minute
-	retryTimes        = 3 //存在备用服务器时，单个服务器重试次数
+	contextTimeout       = 30 * time.Second
+	dialTimeout          = 5 * time.Second
+	testInterval         = 20 * time.Second //t3,超过该时间未收到报文时发送测试激活帧
+	totalCallInterval    = 15 * time.Minute //周期总召唤的间隔
+	commandTimeout       = 10 * time.Second //等待命令确认的超时时间
+	retryTimes           = 3                //存在备用服务器时，单个服务器重试次数
+	ackTimeout           = 10 * time.Second //t2,收到I帧后最长等待时间,期间没有I帧可捎带确认时发送S帧
+	ackWindow            = 8                //w,未确认的I帧达到该数量时立即发送S帧
+	sendWindow           = 12               //k,已发送未被确认的I帧达到该数量时暂停发送I帧
+	writeTimeout         = 10 * time.Second //单次写socket的超时时间
+	terminationTimeout   = 5 * time.Minute  //激活确认后等待激活终止的最长时间,超时后不再跟踪该命令
+	interrogationSpacing = time.Second      //自动召唤多个公共地址时相邻两个公共地址的间隔
+	confirmTimeout       = 15 * time.Second //t1,发送启动激活后等待启动确认的超时时间
+	startDtRetries       = 3                //未收到启动确认时重发启动激活的次数
+	dataBuffer           = 1024             //等待交给task的数据队列长度
 )
 
 //Client 104客户端
 type Client struct {
-	address    string
-	subAddress string
-	curAddress string
-	conn       net.Conn
-	cancel     context.CancelFunc
-	Logger     *logrus.Logger
-	rsn        int16
-	ssn        int16
-	dataChan   chan *APDU
-	sendChan   chan []byte
-	iFrameNum  int
-	task       func(c *APDU)
-	wg         *sync.WaitGroup
+	address        string
+	subAddress     string
+	curAddress     string
+	conn           net.Conn
+	reader         *frameReader       //当前连接的帧读取器
+	ctx            context.Context    //客户端生命周期，Close时取消
+	stop           context.CancelFunc //结束客户端
+	connCtx        context.Context    //当前连接生命周期
+	cancel         context.CancelFunc //结束当前连接
+	closing        chan struct{}      //Close时关闭,通知写协程发送队列中剩余数据
+	closeOnce      sync.Once
+	flushed        chan struct{} //发送队列已清空
+	flushOnce      sync.Once
+	Logger         *logrus.Logger
+	Config         Config
+	mu             sync.Mutex //保护rsn、ssn、conn、connCtx及pending
+	rsn            int16
+	ssn            int16
+	active         bool      //已收到启动确认,可以发送I帧
+	unacked        int       //已接收未确认的I帧数量
+	ackTimer       bool      //t2定时器是否在运行
+	peerAck        int16     //对端确认的接收序号
+	ackedRsn       int16     //最近一次确认的接收序号
+	lastRecv       time.Time //最近一次收到帧的时间
+	lastData       time.Time //最近一次收到监视方向数据的时间
+	lastErr        error     //最近一次连接、读写或解析异常
+	connects       int       //建立连接的次数
+	rejectedFrames int       //因信息对象不在白名单中丢弃的帧数
+	filteredFrames int       //按类型标识过滤丢弃的帧数
+	droppedFrames  int       //数据队列已满时丢弃的帧数
+	decodeErrors   int       //ASDU无法解析而丢弃的I帧数
+	dataChan       chan *APDU
+	sendChan       chan []byte
+	iFrameNum      int
+	task           func(c *APDU)
+	wg             *sync.WaitGroup
+	pending        map[pointKey]*pendingCommand        //等待确认的控制命令
+	pointLocks     map[pointKey]*pointLock             //信息对象的命令锁
+	interrogations map[interrogationKey]*interrogation //正在进行的召唤
+	reads          map[pointKey]*pendingRead           //等待响应的读命令
+	returns        map[pointKey]*pendingReturn         //等待返送信息的命令
+	lastSeen       map[uint16]time.Time                //当前连接各公共地址最近一次收到I帧的时间
+	startDtTicker  Ticker                              //等待启动确认的t1定时器,收到启动确认后停止
+	stations       map[uint16]*StationStatus           //各公共地址的自动总召唤状态
+	pingChans      []chan struct{}                     //等待测试确认帧的Ping
+	conChans       map[[4]byte][]chan struct{}         //等待启动确认、停止确认的StartDT和StopDT
+	limiter        rateLimiter                         //控制命令的令牌桶
+	deliverMu      sync.Mutex                          //保护paused和paused期间缓存的数据,保证交给task的顺序
+	paused         bool
+	pausedFrames   []*APDU
+	allowedPoints  map[PointAddr]bool //Config.AllowedPoints,为nil时不校验
+	valueLog       valueLog           //Config.LogValues的限流状态
+	includeTypes   map[byte]bool      //Config.IncludeTypeIDs,为nil时不过滤
+	excludeTypes   map[byte]bool      //Config.ExcludeTypeIDs
+	sendBusy       bool               //有I帧正在填充序号并进入发送队列,保证I帧按ssn顺序发送
+	sendWaiters    []*sendWaiter      //按优先级排序的等待发送的I帧
+	unackedSent    []time.Time        //已发送未被确认的I帧的发送时间,按发送序号排列
+	sentC          chan struct{}      //发送I帧后通知,启动t1计时
+	notTopical     map[pointKey]bool  //非当前值(NT=1)的测量值,只在读协程中访问
 }
 
 //NewClient 初始化客户端,连接失败，每隔10秒重试
@@ -43,27 +100,139 @@ func NewClient(address string, logger *logrus.Logger, subAddress ...string) *Cli
 	if len(subAddress) == 1 && subAddress[0] != "" {
 		subAddr = subAddress[0]
 	}
+	ctx, stop := context.WithCancel(context.Background())
 	return &Client{
-		address:    address,
-		subAddress: subAddr,
-		curAddress: address,
-		dataChan:   make(chan *APDU, 1),
-		sendChan:   make(chan []byte, 1),
-		Logger:     logger,
-		wg:         new(sync.WaitGroup),
+		address:        address,
+		subAddress:     subAddr,
+		curAddress:     address,
+		dataChan:       make(chan *APDU, dataBuffer),
+		sendChan:       make(chan []byte, 1),
+		sentC:          make(chan struct{}, 1),
+		Logger:         logger,
+		wg:             new(sync.WaitGroup),
+		ctx:            ctx,
+		stop:           stop,
+		closing:        make(chan struct{}),
+		flushed:        make(chan struct{}),
+		pending:        make(map[pointKey]*pendingCommand),
+		pointLocks:     make(map[pointKey]*pointLock),
+		interrogations: make(map[interrogationKey]*interrogation),
+		reads:          make(map[pointKey]*pendingRead),
+		returns:        make(map[pointKey]*pendingReturn),
+		stations:       make(map[uint16]*StationStatus),
 	}
 }
 
-//Run 运行
+//Run 运行,调用Close后返回
 func (c *Client) Run(task func(*APDU)) {
-	go c.handleSignal()
-	//定时器，每15分钟发送一次总召唤
-	ticker := time.NewTicker(totalCallInterval)
+	c.RunContext(context.Background(), task)
+}
+
+//RunContext 运行连接、读写和定时器,断线自动重连。ctx取消时与Close相同关闭客户端并返回ctx.Err(),
+//调用Close后返回nil,Config.ReconnectPolicy停止重连时返回最近一次连接异常,可配合errgroup使用。
+//ctx已取消时不建立连接,关闭客户端后立即返回ctx.Err()
+func (c *Client) RunContext(ctx context.Context, task func(*APDU)) error {
+	if ctx.Err() != nil {
+		c.Close()
+		return ctx.Err()
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-c.ctx.Done():
+		}
+	}()
+	err := c.run(task)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+//run 建立连接直至客户端关闭,重连策略停止重连时返回最近一次连接异常。已关闭的客户端不再连接,直接返回nil
+func (c *Client) run(task func(*APDU)) error {
+	if c.ctx.Err() != nil || c.isClosing() {
+		c.Logger.Info("客户端已关闭,不再连接")
+		return nil
+	}
+	if !LibraryMode {
+		go c.handleSignal()
+	}
+	if len(c.Config.AllowedPoints) > 0 {
+		c.allowedPoints = make(map[PointAddr]bool, len(c.Config.AllowedPoints))
+		for _, p := range c.Config.AllowedPoints {
+			c.allowedPoints[p] = true
+		}
+	}
+	c.includeTypes = typeIDSet(c.Config.IncludeTypeIDs)
+	if c.Config.DataBuffer > 0 {
+		c.deliverMu.Lock()
+		c.dataChan = make(chan *APDU, c.Config.DataBuffer)
+		c.deliverMu.Unlock()
+	}
+	c.excludeTypes = typeIDSet(c.Config.ExcludeTypeIDs)
+	if t3 := c.testInterval(); t3 > 0 && t3 <= c.confirmTimeout() {
+		c.Logger.Warnf("t3(%v)应大于t1(%v)", t3, c.confirmTimeout())
+	}
+	//未配置StaleDataTimeout时staleC为nil,不检查
+	var staleC <-chan time.Time
+	if c.Config.StaleDataTimeout > 0 {
+		staleTicker := c.clock().NewTicker(c.Config.StaleDataTimeout)
+		defer staleTicker.Stop()
+		staleC = staleTicker.C()
+	}
 	for {
-		c.conn = c.dail()
-		c.sendUFrame(startDtAct)
-		ctx, cancel := context.WithCancel(context.Background())
+		conn, err := c.dail()
+		if err != nil {
+			//重连策略停止重连,关闭客户端
+			c.Close()
+			c.Logger.Println("停止重连，客户端关闭")
+			return err
+		}
+		if conn == nil {
+			break
+		}
+		c.reader = newFrameReader(conn)
+		c.reader.onSkipped = c.Config.OnFramingError
+		ctx, cancel := context.WithCancel(c.ctx)
+		c.mu.Lock()
+		c.conn = conn
+		c.connCtx = ctx
 		c.cancel = cancel
+		//新连接的序号和计数从0开始,配置了SeqStore时第一次连接恢复保存的序号
+		if c.connects == 0 && c.Config.SeqStore != nil {
+			c.restoreSeq()
+		} else {
+			c.resetSeq()
+		}
+		c.iFrameNum = 0
+		c.active = false
+		c.conChans = make(map[[4]byte][]chan struct{})
+		c.connects++
+		c.lastSeen = make(map[uint16]time.Time)
+		c.resetRateLimit()
+		c.lastData = c.clock().Now()
+		c.mu.Unlock()
+		//t1内未收到启动确认时重发启动激活
+		startDtTicker := c.clock().NewTicker(c.confirmTimeout())
+		c.mu.Lock()
+		c.startDtTicker = startDtTicker
+		c.mu.Unlock()
+		startDtRetries := 0
+		//周期总召唤和t3分别计时,每次建立连接后重新计时,间隔为负数时不启动
+		var totalCallC, t3C, t1C <-chan time.Time
+		var totalCallTicker Ticker
+		if d := c.totalCallInterval(); d > 0 {
+			totalCallTicker = c.clock().NewTicker(d)
+			totalCallC = totalCallTicker.C()
+		}
+		t3 := c.testInterval()
+		if t3 > 0 {
+			t3C = c.clock().After(t3)
+		}
+		connectedAt := c.clock().Now()
+		c.sendUFrame(startDtAct)
 		c.wg.Add(3)
 		go c.read(ctx)
 		go c.write(ctx)
@@ -71,54 +240,179 @@ func (c *Client) Run(task func(*APDU)) {
 	cronLoop:
 		for {
 			select {
-			case <-ticker.C:
-				c.Logger.Info("每隔15分钟发送一次总召唤")
+			case <-totalCallC:
+				c.Logger.Infof("每隔%v发送一次总召唤", c.totalCallInterval())
 				c.sendTotalCall()
+			case <-t3C:
+				//收到任何报文后t3重新计时
+				idle := c.clock().Now().Sub(c.lastActivity(connectedAt))
+				if idle >= t3 {
+					c.Logger.Debugf("%v内未收到报文,发送测试激活帧", t3)
+					c.sendUFrame(testFrAct)
+					idle = 0
+				}
+				t3C = c.clock().After(t3 - idle)
+			case <-c.sentC:
+				if t1C == nil {
+					t1C = c.clock().After(c.confirmTimeout())
+				}
+			case <-t1C:
+				//最早一个未被确认的I帧超过t1时断开重连
+				t1 := c.confirmTimeout()
+				age, ok := c.oldestUnacked()
+				switch {
+				case !ok:
+					t1C = nil
+				case age >= t1:
+					c.Logger.Errorf("已发送的I帧超过%v未被确认,重新连接", t1)
+					c.setLastErr(ErrAckTimeout)
+					t1C = nil
+					cancel()
+				default:
+					t1C = c.clock().After(t1 - age)
+				}
+			case <-staleC:
+				if c.dataStale() {
+					c.Logger.Warnf("超过%v未收到数据,重新发送总召唤", c.Config.StaleDataTimeout)
+					c.sendTotalCall()
+				}
+			case <-startDtTicker.C():
+				if startDtRetries >= c.startDtRetries() {
+					c.Logger.Errorf("重发%d次启动激活后仍未收到启动确认,重新连接", startDtRetries)
+					c.setLastErr(ErrStartDtTimeout)
+					startDtTicker.Stop()
+					cancel()
+					continue
+				}
+				startDtRetries++
+				c.Logger.Warnf("%v内未收到启动确认,第%d次重发启动激活", c.confirmTimeout(), startDtRetries)
+				c.sendUFrame(startDtAct)
 			case <-ctx.Done():
 				break cronLoop
 			}
 		}
+		startDtTicker.Stop()
+		if totalCallTicker != nil {
+			totalCallTicker.Stop()
+		}
 		c.Logger.Info("等待goroutine退出")
+		//关闭连接以唤醒阻塞在读操作上的协程
+		c.conn.Close()
 		c.wg.Wait()
-		if c.conn != nil {
-			c.conn.Close()
+		//丢弃上一个连接未发送的数据
+	drainLoop:
+		for {
+			select {
+			case <-c.sendChan:
+			default:
+				break drainLoop
+			}
+		}
+		c.failPendingCommands(ErrNotConnected)
+		c.failInterrogations(ErrNotConnected)
+		c.failReads(ErrNotConnected)
+		c.failReturns(ErrNotConnected)
+		if c.ctx.Err() != nil || c.isClosing() {
+			break
 		}
-		ctx, cancel = context.WithCancel(context.Background())
-		c.cancel = cancel
-		c.rsn = 0
-		c.ssn = 0
-		c.iFrameNum = 0
 	}
+	c.Logger.Println("断开服务器连接，客户端关闭")
+	return nil
 }
 
-//建立tcp连接，支持重试和主备切换
-func (c *Client) dail() net.Conn {
-	var conn net.Conn
+//lastActivity 当前连接最近一次收到报文的时间,未收到报文时为建立连接的时间
+func (c *Client) lastActivity(connectedAt time.Time) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastRecv.After(connectedAt) {
+		return c.lastRecv
+	}
+	return connectedAt
+}
+
+//Close 关闭客户端,可在task中调用,不会阻塞。
+//配置了FlushTimeout时,先发送队列中剩余的数据,清空或超时后再断开连接
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.closing)
+		if c.Config.FlushTimeout <= 0 || !c.connected() {
+			c.stop()
+			return
+		}
+		go func() {
+			select {
+			case <-c.flushed:
+			case <-c.clock().After(c.Config.FlushTimeout):
+				c.Logger.Warn("发送剩余数据超时")
+			}
+			c.stop()
+		}()
+	})
+}
+
+//Shutdown 正常关闭客户端:先发送停止激活(STOPDT_ACT)并等待停止确认,等待时间由ctx控制,再调用Close断开连接。
+//未连接时直接关闭,等待停止确认失败时仍会关闭并返回该异常
+func (c *Client) Shutdown(ctx context.Context) error {
 	var err error
+	if c.connected() {
+		if err = c.StopDT(ctx); err != nil {
+			c.Logger.Warnf("等待停止确认失败: %v", err)
+		}
+	}
+	c.Close()
+	return err
+}
+
+//isClosing 是否已调用Close
+func (c *Client) isClosing() bool {
+	select {
+	case <-c.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+//建立tcp连接，支持重试和主备切换,客户端关闭时返回nil。
+//Config.ReconnectPolicy停止重连时返回最近一次连接异常
+func (c *Client) dail() (net.Conn, error) {
 	c.Logger.Infof("开始连接服务器:%v", c.curAddress)
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	if c.Config.LocalAddr != nil {
+		dialer.LocalAddr = c.Config.LocalAddr
+		c.Logger.Infof("使用本地地址:%v", c.Config.LocalAddr)
+	}
 	i := -1
-	for {
-		conn, err = net.DialTimeout("tcp", c.curAddress, dialTimeout)
-		if err != nil {
-			time.Sleep(dialTimeout)
-			i++
-			if i == retryTimes && c.subAddress != "" {
-				i = 0
-				if c.curAddress == c.address {
-					c.curAddress = c.subAddress
-				} else {
-					c.curAddress = c.address
-				}
-				c.Logger.Infof("尝试超过3次，切换服务器为:%s,开始第%d次重试", c.curAddress, i+1)
+	for attempt := 1; ; attempt++ {
+		conn, err := dialer.Dial("tcp", c.curAddress)
+		if err == nil {
+			c.Logger.Infof("连接服务器成功,远端地址:%v,本地地址:%v", conn.RemoteAddr(), conn.LocalAddr())
+			return conn, nil
+		}
+		c.setLastErr(err)
+		retry, wait := c.reconnectWait(attempt, err)
+		if !retry {
+			c.Logger.Errorf("连续%d次连接服务器失败,停止重连: %v", attempt, err)
+			return nil, err
+		}
+		select {
+		case <-c.ctx.Done():
+			return nil, nil
+		case <-c.clock().After(wait):
+		}
+		i++
+		if i == retryTimes && c.subAddress != "" {
+			i = 0
+			if c.curAddress == c.address {
+				c.curAddress = c.subAddress
 			} else {
-				c.Logger.Infof("连接服务器失败，开始第%d次重试", i+1)
+				c.curAddress = c.address
 			}
+			c.Logger.Infof("尝试超过3次，切换服务器为:%s,开始第%d次重试", c.curAddress, i+1)
 		} else {
-			c.Logger.Infoln("连接服务器成功")
-			break
+			c.Logger.Infof("连接服务器失败，开始第%d次重试", i+1)
 		}
 	}
-	return conn
 }
 
 //Read 读数据
@@ -150,20 +444,57 @@ func (c *Client) write(ctx context.Context) {
 		c.wg.Done()
 		c.Logger.Info("socket写协程停止")
 	}()
+	closing := c.closing
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case data := <-c.sendChan:
-			_, err := c.conn.Write(data)
-			if err != nil {
+			if err := c.writeData(data); err != nil {
+				return
+			}
+		case <-closing:
+			closing = nil
+			if err := c.flush(); err != nil {
 				return
 			}
 		}
 	}
 }
 
-//handler 处理接收到的已解析数据
+//writeData 设置写超时后写socket,对端长时间不读取导致超时时返回错误,由写协程断开重连
+func (c *Client) writeData(data []byte) error {
+	timeout := writeTimeout
+	if c.Config.WriteTimeout > 0 {
+		timeout = c.Config.WriteTimeout
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(timeout))
+	if _, err := c.conn.Write(data); err != nil {
+		c.Logger.Errorf("write socket写操作异常: %v", err)
+		c.setLastErr(err)
+		return err
+	}
+	return nil
+}
+
+//flush 发送队列中剩余的数据
+func (c *Client) flush() error {
+	defer c.flushOnce.Do(func() {
+		close(c.flushed)
+	})
+	for {
+		select {
+		case data := <-c.sendChan:
+			if err := c.writeData(data); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+//handler 处理接收到的已解析数据,按接收顺序依次调用task,task阻塞时数据在dataChan中排队
 func (c *Client) handler(ctx context.Context, task func(c *APDU)) {
 	c.Logger.Info("数据处理协程启动")
 	defer func() {
@@ -174,107 +505,234 @@ func (c *Client) handler(ctx context.Context, task func(c *APDU)) {
 	for {
 		select {
 		case resp := <-c.dataChan:
-			c.Logger.Debugf("接收到数据类型:%d,原因:%d,长度:%d", resp.ASDU.TypeID, resp.ASDU.Cause, len(resp.Signals))
-			go task(resp)
+			c.Logger.Debugf("接收到数据类型:%s,原因:%d,长度:%d", TypeIDName(resp.ASDU.TypeID), resp.ASDU.Cause, len(resp.Signals))
+			c.runTask(task, resp)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+//runTask 调用task,task中的panic被恢复并记录,不影响读写协程和后续数据的处理
+func (c *Client) runTask(task func(*APDU), apdu *APDU) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.Logger.Errorf("处理数据时发生panic: %v\n%s", r, debug.Stack())
+			if c.Config.OnTaskPanic != nil {
+				c.Config.OnTaskPanic(r, apdu)
+			}
+		}
+	}()
+	task(apdu)
+}
+
+//emitSignals 按信息对象逐个调用OnSignal
+func (c *Client) emitSignals(apdu *APDU) {
+	if c.Config.OnSignal == nil {
+		return
+	}
+	for _, s := range apdu.Signals {
+		c.Config.OnSignal(apdu.ASDU.PublicAddress, s)
+	}
+}
+
+//checkTopical 测量值的非当前值标志NT变化时调用OnStaleData或OnTopicalData
+func (c *Client) checkTopical(apdu *APDU) {
+	if c.Config.OnStaleData == nil && c.Config.OnTopicalData == nil {
+		return
+	}
+	switch apdu.ASDU.TypeID {
+	case MMeNa1, MMeNb1, MMeNc1, MMeTd1, MMeTe1, MMeTf1:
+	default:
+		return
+	}
+	if c.notTopical == nil {
+		c.notTopical = make(map[pointKey]bool)
+	}
+	commonAddr := apdu.ASDU.PublicAddress
+	for _, s := range apdu.Signals {
+		key := pointKey{commonAddr, s.Address}
+		stale := s.QDS().NotTopical
+		if stale == c.notTopical[key] {
+			continue
+		}
+		if stale {
+			c.notTopical[key] = true
+			if c.Config.OnStaleData != nil {
+				c.Config.OnStaleData(commonAddr, s.Address)
+			}
+		} else {
+			delete(c.notTopical, key)
+			if c.Config.OnTopicalData != nil {
+				c.Config.OnTopicalData(commonAddr, s.Address)
+			}
+		}
+	}
+}
+
 //ParseData 解析接收到的数据
 func (c *Client) parseData(ctx context.Context) error {
 	handleErr := func(tag string, err error) {
+		if ctx.Err() != nil {
+			return
+		}
 		c.Logger.Errorf("%s read socket读操作异常: %v", tag, err)
+		c.setLastErr(err)
 	}
 
-	buf := make([]byte, 2)
-	//读取启动符和长度
-	n, err := c.conn.Read(buf)
+	c.conn.SetReadDeadline(time.Now().Add(contextTimeout))
+	frame, skipped, err := c.reader.readFrame()
+	if skipped > 0 {
+		c.Logger.Warnf("丢弃%d个字节后重新同步", skipped)
+	}
 	if err != nil {
-		handleErr("读取启动符和长度", err)
+		handleErr("读取APDU", err)
 		return err
 	}
-	c.conn.SetDeadline(time.Now().Add(contextTimeout))
-	if n == 0 {
-		c.Logger.Info("读取到空数据,10s后继续读取数据")
-		time.Sleep(10 * time.Second)
+	c.Logger.Debugf("收到原始数据: [% X],长度:%d", frame, len(frame))
+	c.mu.Lock()
+	c.lastRecv = c.clock().Now()
+	c.mu.Unlock()
+	apdu := new(APDU)
+	err = apdu.parseAPDU(frame[2:])
+	if f, ok := apdu.CtrFrame.(IFrame); ok && err != nil {
+		//帧长度和控制域正确,只是ASDU无法解析:仍推进接收序号并确认,丢弃该帧
+		c.Logger.Warnf("解析ASDU异常,丢弃该帧: %v", err)
+		c.setLastErr(err)
+		apdu.Raw = append([]byte(nil), frame...)
+		c.setPeerAck(f.Recv, apdu)
+		c.checkSendSeq(f.Send, apdu)
+		c.incrRsn()
+		c.mu.Lock()
+		c.decodeErrors++
+		c.mu.Unlock()
+		c.ack()
 		return nil
 	}
-	length := int(buf[1])
-	//读取正文
-	contentBuf := make([]byte, length)
-	n, err = c.conn.Read(contentBuf)
 	if err != nil {
-		handleErr("读取正文", err)
-		return err
+		c.Logger.Warnf("解析APDU异常,重新同步: %v", err)
+		c.setLastErr(err)
+		c.reader.resync(frame)
+		return nil
 	}
-	//长度不够继续读取，直至达到期望长度
-	i := 1
-	for n < length {
-		i++
-		nextLength := length - n
-		nextBuf := make([]byte, nextLength)
-		m, err := c.conn.Read(nextBuf)
-		if err != nil {
-			handleErr("循环读取正文", err)
-			return err
-		}
-		contentBuf = append(contentBuf[:n], nextBuf[:m]...)
-		n = len(contentBuf)
-		c.Logger.Debugf("循环读取数据，当前为第%d次读取，期望长度:%d,本次长度:%d,当前总长度:%d", i, length, m, n)
+	//读缓冲区会被复用,保存副本
+	apdu.Raw = append([]byte(nil), frame...)
+	if apdu.ASDU != nil && apdu.ASDU.ObjectError != nil {
+		c.Logger.Warnf("APDU报文[% X]部分解析: %v", frame, apdu.ASDU.ObjectError)
 	}
-	c.Logger.Debugf("收到原始数据: [% X],rsn:%d,ssn:%d,长度:%d", append(buf, contentBuf[:n]...), c.rsn, c.ssn, 2+len(contentBuf[:n]))
-	apdu := new(APDU)
-	err = apdu.parseAPDU(contentBuf[:n])
-	if err != nil {
-		c.Logger.Warnf("解析APDU异常: %v", err)
-		c.Logger.Panicln("退出程序")
-		return err
+	if apdu.ASDU != nil && !apdu.ASDU.Cause.Valid() {
+		c.Logger.Warnf("APDU报文[% X]传输原因[%d]为保留或未定义值", frame, apdu.ASDU.Cause)
 	}
-	switch apdu.CtrFrame.(type) {
+	switch f := apdu.CtrFrame.(type) {
 	case IFrame:
+		c.setPeerAck(f.Recv, apdu)
+		c.checkSendSeq(f.Send, apdu)
 		c.incrRsn()
+		c.mu.Lock()
+		c.lastSeen[apdu.ASDU.PublicAddress] = c.lastRecv
+		c.mu.Unlock()
+		if !c.checkCommonAddr(apdu) || !c.checkTypeID(apdu) || !c.checkPoints(apdu) {
+			c.ack()
+			return nil
+		}
 		switch apdu.ASDU.TypeID {
 		case MEiNA1:
 			c.Logger.Info("接收到初始化结束，开始发送总召唤")
-			c.sendSFrame()
+			c.ack()
 			c.sendTotalCall()
 		case CIcNa1:
-			if apdu.ASDU.Cause == 7 {
+			c.handleInterrogationResponse(apdu)
+			c.ack()
+			if apdu.ASDU.Cause == CauseActivationCon {
 				c.Logger.Info("接收总召唤确认帧")
-				c.sendSFrame()
-			} else if apdu.ASDU.Cause == 10 {
+			} else if apdu.ASDU.Cause == CauseActivationTermination {
 				c.Logger.Info("接收总召唤结束帧")
-				c.sendSFrame()
-				c.Logger.Info("发送电度总召唤")
-				c.sendElectricityTotalCall()
+				c.stationCallComplete(apdu.ASDU.PublicAddress)
+				if c.Config.OnInterrogationComplete != nil {
+					c.Config.OnInterrogationComplete(apdu.ASDU.PublicAddress)
+				}
+				if !c.Config.SkipCounterInterrogation {
+					c.Logger.Info("发送电度总召唤")
+					c.sendElectricityTotalCall(apdu.ASDU.PublicAddress)
+				}
 			}
 		case CCiNa1:
-			if apdu.ASDU.Cause == 7 {
+			c.handleInterrogationResponse(apdu)
+			if apdu.ASDU.Cause == CauseActivationCon {
 				c.Logger.Info("接收电度总召唤确认帧")
-			} else if apdu.ASDU.Cause == 10 {
+			} else if apdu.ASDU.Cause == CauseActivationTermination {
 				c.Logger.Info("接收电度总召唤结束帧")
 			}
-			c.sendSFrame()
+			c.ack()
+		case CScNa1, CDcNa1, CRcNa1, CSeNb1, CSeNc1, CScTa1, CBoTa1, CCsNa1, CRpNa1:
+			c.handleCommandResponse(apdu)
+			c.ack()
+		case CRdNa1:
+			c.handleReadReject(apdu)
+			c.ack()
+		case MEpTd1, MEpTe1, MEpTf1:
+			c.handleProtectionEvents(apdu)
+			fallthrough
 		default:
+			c.mu.Lock()
+			c.lastData = c.clock().Now()
 			c.iFrameNum++
-			c.Logger.Debugf("接收到第%d个I帧", c.iFrameNum)
-			c.dataChan <- apdu
-			c.sendSFrame()
+			iFrameNum := c.iFrameNum
+			c.mu.Unlock()
+			if c.Config.LogIFrames {
+				c.Logger.Debugf("接收到第%d个I帧", iFrameNum)
+			}
+			//先确认再交给task,处理缓慢时不影响确认,避免对端t1超时断开
+			c.ack()
+			c.collectInterrogation(apdu)
+			c.handleReadResponse(apdu)
+			c.handleReturnInfo(apdu)
+			c.logValues(apdu)
+			if c.Config.OnData != nil {
+				c.Config.OnData(apdu)
+			}
+			c.emitSignals(apdu)
+			c.checkTopical(apdu)
+			if err := c.deliver(ctx, apdu); err != nil {
+				return err
+			}
 		}
 	case SFrame:
 		c.Logger.Debugln("接收到S帧")
+		c.setPeerAck(f.Recv, apdu)
 	case UFrame:
 		c.Logger.Debugln("接收到U帧")
-		uFrame := apdu.CtrFrame.(UFrame)
-		switch uFrame.cmd {
+		switch f.cmd {
 		case startDtCon:
 			c.Logger.Info("U帧为启动确认帧，发送总召唤")
+			c.mu.Lock()
+			if c.startDtTicker != nil {
+				c.startDtTicker.Stop()
+			}
+			c.mu.Unlock()
+			c.setActive(true)
+			c.notifyCon(startDtCon)
 			c.sendTotalCall()
+		case stopDtAct:
+			//对端停止数据传输:先确认已收到的I帧,再回复停止确认,之后不再发送I帧
+			c.Logger.Info("U帧为停止激活帧,发送停止确认帧")
+			c.flushAck()
+			c.setActive(false)
+			c.sendUFrame(stopDtCon)
+		case stopDtCon:
+			c.Logger.Info("U帧为停止确认帧")
+			c.notifyCon(stopDtCon)
 		case testFrAct:
 			c.Logger.Info("U帧为测试激活帧,发送测试确认帧")
 			c.sendUFrame(testFrCon)
+		case testFrCon:
+			c.Logger.Debugln("U帧为测试确认帧")
+			c.mu.Lock()
+			for _, ch := range c.pingChans {
+				close(ch)
+			}
+			c.pingChans = nil
+			c.mu.Unlock()
 		}
 	default:
 		c.Logger.Debugln("接收到未知帧")
@@ -282,68 +740,426 @@ func (c *Client) parseData(ctx context.Context) error {
 	return nil
 }
 
+//checkCommonAddr 校验公共地址是否为预期地址,返回false表示丢弃该帧
+func (c *Client) checkCommonAddr(apdu *APDU) bool {
+	if len(c.Config.CommonAddrs) == 0 {
+		return true
+	}
+	commonAddr := apdu.ASDU.PublicAddress
+	for _, addr := range c.Config.CommonAddrs {
+		if addr == commonAddr {
+			return true
+		}
+	}
+	c.Logger.Warnf("收到非预期的公共地址:%d,类型:%s,原因:%d", commonAddr, TypeIDName(apdu.ASDU.TypeID), apdu.ASDU.Cause)
+	if c.Config.OnUnexpectedCommonAddr != nil {
+		c.Config.OnUnexpectedCommonAddr(commonAddr, apdu)
+	}
+	return !c.Config.RejectUnexpectedCommonAddr
+}
+
+//checkPoints 校验监视方向过程信息(类型1~44)的信息对象是否都在白名单中,返回false表示丢弃该帧
+func (c *Client) checkPoints(apdu *APDU) bool {
+	if c.allowedPoints == nil || apdu.ASDU.TypeID >= CScNa1 {
+		return true
+	}
+	allowed := true
+	for _, s := range apdu.Signals {
+		p := PointAddr{apdu.ASDU.PublicAddress, s.Address}
+		if c.allowedPoints[p] {
+			continue
+		}
+		allowed = false
+		c.Logger.Warnf("收到白名单之外的信息对象,公共地址:%d,信息对象地址:%d,类型:%s", p.CommonAddr, p.IOA, TypeIDName(apdu.ASDU.TypeID))
+		if c.Config.OnRejectedPoint != nil {
+			c.Config.OnRejectedPoint(p.CommonAddr, s)
+		}
+	}
+	if !allowed {
+		c.mu.Lock()
+		c.rejectedFrames++
+		c.mu.Unlock()
+	}
+	return allowed
+}
+
+//checkTypeID 按Config.IncludeTypeIDs和ExcludeTypeIDs过滤监视方向过程信息(类型1~44),返回false表示丢弃该帧
+func (c *Client) checkTypeID(apdu *APDU) bool {
+	typeID := apdu.ASDU.TypeID
+	if typeID >= CScNa1 {
+		return true
+	}
+	if (c.includeTypes == nil || c.includeTypes[typeID]) && !c.excludeTypes[typeID] {
+		return true
+	}
+	c.mu.Lock()
+	c.filteredFrames++
+	c.mu.Unlock()
+	return false
+}
+
+//typeIDSet 转换为以类型标识为键的集合,为空时返回nil
+func typeIDSet(typeIDs []byte) map[byte]bool {
+	if len(typeIDs) == 0 {
+		return nil
+	}
+	m := make(map[byte]bool, len(typeIDs))
+	for _, typeID := range typeIDs {
+		m[typeID] = true
+	}
+	return m
+}
+
 //sendUFrame 发送U帧
 func (c *Client) sendUFrame(cmd [4]byte) {
-	data := convertBytes(convert4BytesToSlice(cmd))
+	data := encodeUFrame(cmd)
 	c.Logger.Debugf("发送U帧: [% X]", data)
-	c.sendChan <- data
+	c.send(data)
+}
+
+//setActive 设置数据传输状态,状态变化时调用OnActiveChange
+func (c *Client) setActive(active bool) {
+	c.mu.Lock()
+	changed := c.active != active
+	c.active = active
+	c.mu.Unlock()
+	if changed && c.Config.OnActiveChange != nil {
+		c.Config.OnActiveChange(active)
+	}
+}
+
+//ack 确认收到的I帧:未确认数量达到w时立即发送S帧,否则启动t2定时器,
+//t2内发送的I帧捎带了rsn,定时器到期时没有未确认的I帧则不再发送S帧
+func (c *Client) ack() {
+	window := ackWindow
+	if c.Config.AckWindow > 0 {
+		window = c.Config.AckWindow
+	}
+	timeout := ackTimeout
+	if c.Config.AckTimeout > 0 {
+		timeout = c.Config.AckTimeout
+	}
+	c.mu.Lock()
+	c.unacked++
+	if c.unacked >= window {
+		c.mu.Unlock()
+		c.sendSFrame()
+		return
+	}
+	if c.ackTimer || c.connCtx == nil {
+		c.mu.Unlock()
+		return
+	}
+	c.ackTimer = true
+	ctx := c.connCtx
+	c.mu.Unlock()
+	go func() {
+		select {
+		case <-c.clock().After(timeout):
+		case <-ctx.Done():
+			return
+		}
+		c.mu.Lock()
+		c.ackTimer = false
+		unacked := c.unacked
+		c.mu.Unlock()
+		if unacked > 0 {
+			c.sendSFrame()
+		}
+	}()
+}
+
+//flushAck 有未确认的I帧时发送S帧,严格确认(StrictAckWindow)时不提前确认
+func (c *Client) flushAck() {
+	c.mu.Lock()
+	unacked := c.unacked
+	c.mu.Unlock()
+	if unacked > 0 && !c.Config.StrictAckWindow {
+		c.sendSFrame()
+	}
 }
 
 //sendSFrame 发送S帧
 func (c *Client) sendSFrame() {
-	rsnBytes := parseLittleEndianUInt16(uint16(c.rsn << 1))
-	sendBytes := make([]byte, 0, 0)
-	sendBytes = append(sendBytes, 0x01, 0x00)
-	sendBytes = append(sendBytes, rsnBytes...)
-	data := convertBytes(sendBytes)
+	c.mu.Lock()
+	data := encodeSFrame(c.rsn)
+	c.unacked = 0
+	c.ackedRsn = c.rsn
+	c.saveSeq()
+	c.mu.Unlock()
 	c.Logger.Debugf("发送S帧: [% X]", data)
-	c.sendChan <- data
+	c.send(data)
+}
+
+//sendIFrame 发送I帧,填充收发序号后ssn加1,同时确认已收到的I帧(严格确认时除外)。未收到启动确认时返回ErrNotActive
+func (c *Client) sendIFrame(asdu []byte) ([]byte, error) {
+	return c.sendIFrameTimeout(asdu, 0)
 }
 
-//sendTotalCall 发送总召唤
+//sendIFrameTimeout 发送I帧,轮到发送前(包括k窗口已满时等待对端确认)最多等待timeout,超时返回ErrCommandTimeout,
+//timeout为0时一直等待至连接断开
+func (c *Client) sendIFrameTimeout(asdu []byte, timeout time.Duration) ([]byte, error) {
+	if err := checkSendCause(asdu); err != nil {
+		return nil, err
+	}
+	if err := c.acquireSend(c.sendPriority(asdu[0]), timeout); err != nil {
+		return nil, err
+	}
+	defer c.releaseSend()
+	c.mu.Lock()
+	if c.connCtx == nil || c.connCtx.Err() != nil {
+		c.mu.Unlock()
+		return nil, ErrNotConnected
+	}
+	if !c.active {
+		c.mu.Unlock()
+		return nil, ErrNotActive
+	}
+	rsn := c.ackedRsn
+	if !c.Config.StrictAckWindow {
+		//捎带确认已收到的全部I帧
+		rsn = c.rsn
+		c.unacked = 0
+		c.ackedRsn = c.rsn
+	}
+	data := encodeIFrame(c.ssn, rsn, asdu)
+	c.incrSsn()
+	c.saveSeq()
+	c.unackedSent = append(c.unackedSent, c.clock().Now())
+	c.mu.Unlock()
+	select {
+	case c.sentC <- struct{}{}:
+	default:
+	}
+	c.send(data)
+	return data, nil
+}
+
+//sendTotalCall 向每个自动召唤的公共地址发送总召唤
 func (c *Client) sendTotalCall() {
-	ssnBytes := parseLittleEndianUInt16(uint16(c.ssn << 1))
-	rsnBytes := parseLittleEndianUInt16(uint16(c.rsn << 1))
-	totalCallData := make([]byte, 0, 0)
-	totalCallData = append(totalCallData, ssnBytes...)
-	totalCallData = append(totalCallData, rsnBytes...)
-	totalCallData = append(totalCallData, 0x64, 0x01, 0x06, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x14)
-	data := convertBytes(totalCallData)
-	c.Logger.Debugf("发送总召唤: [% X]", data)
-	c.sendChan <- data
-}
-
-//sendTotalCall 发送电度总召唤
-func (c *Client) sendElectricityTotalCall() {
-	ssnBytes := parseLittleEndianUInt16(uint16(c.ssn << 1))
-	rsnBytes := parseLittleEndianUInt16(uint16(c.rsn << 1))
-	totalCallData := make([]byte, 0, 0)
-	totalCallData = append(totalCallData, ssnBytes...)
-	totalCallData = append(totalCallData, rsnBytes...)
-	totalCallData = append(totalCallData, 0x65, 0x01, 0x06, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x05)
-	data := convertBytes(totalCallData)
-	c.Logger.Debugf("发送电度总召唤: [% X]", data)
-	c.sendChan <- data
+	c.sendStationCalls(CIcNa1, c.defaultQOI(), "总召唤")
+}
+
+//sendElectricityTotalCall 在独立的协程中向commonAddr发送电度总召唤,见sendStationCalls
+func (c *Client) sendElectricityTotalCall(commonAddr uint16) {
+	c.mu.Lock()
+	ctx := c.connCtx
+	c.mu.Unlock()
+	if ctx == nil {
+		return
+	}
+	go c.sendStationCall(ctx, CCiNa1, c.defaultQCC(), "电度总召唤", commonAddr)
+}
+
+//sendStationCalls 在独立的协程中向每个自动召唤的公共地址发送召唤命令,相邻两个公共地址间隔InterrogationSpacing,不等待召唤结束。
+//由读协程调用,发送窗口已满时sendIFrame等待对端确认,而确认只能由读协程处理,不能在读协程中等待
+func (c *Client) sendStationCalls(typeID byte, qualifier byte, name string) {
+	c.mu.Lock()
+	ctx := c.connCtx
+	c.mu.Unlock()
+	if ctx == nil {
+		return
+	}
+	addrs := c.interrogationAddrs()
+	go func() {
+		for i, commonAddr := range addrs {
+			if i > 0 {
+				select {
+				case <-c.clock().After(c.interrogationSpacing()):
+				case <-ctx.Done():
+					return
+				}
+			}
+			c.sendStationCall(ctx, typeID, qualifier, name, commonAddr)
+		}
+	}()
+}
+
+//sendStationCall 向commonAddr发送召唤命令,不等待召唤结束。召唤与Interrogate等同样登记在interrogations中,
+//Interrogate等在自动召唤结束后才发送;该公共地址正在进行Interrogate等召唤时跳过,上一次自动召唤未结束时不再等待
+func (c *Client) sendStationCall(ctx context.Context, typeID byte, qualifier byte, name string, commonAddr uint16) {
+	key := interrogationKey{commonAddr, typeID}
+	it := newInterrogation(qualifier, 0)
+	it.auto = true
+	c.mu.Lock()
+	prev, ok := c.interrogations[key]
+	if ok && !prev.auto {
+		c.mu.Unlock()
+		c.Logger.Infof("公共地址%d正在进行召唤,不发送%s", commonAddr, name)
+		return
+	}
+	c.interrogations[key] = it
+	c.mu.Unlock()
+	if ok {
+		notify(prev.done, nil)
+	}
+	//先记录再发送,避免召唤结束先于记录到达
+	if typeID == CIcNa1 {
+		c.stationCallSent(commonAddr)
+	}
+	data, err := c.sendIFrame(encodeCommandASDU(typeID, c.cause(CauseActivation), c.Config.Originator, commonAddr, 0, []byte{qualifier}))
+	if err != nil {
+		c.Logger.Warnf("发送%s失败,公共地址:%d: %v", name, commonAddr, err)
+		if typeID == CIcNa1 {
+			c.stationCallFailed(commonAddr)
+		}
+		c.endInterrogation(key, it)
+		return
+	}
+	c.Logger.Debugf("发送%s,公共地址:%d: [% X]", name, commonAddr, data)
+	go c.waitStationCall(ctx, key, it)
+}
+
+//waitStationCall 等待自动召唤结束,最多等待Config.InterrogationTimeout,未配置时为terminationTimeout
+func (c *Client) waitStationCall(ctx context.Context, key interrogationKey, it *interrogation) {
+	defer c.endInterrogation(key, it)
+	timeout := c.Config.InterrogationTimeout
+	if timeout <= 0 {
+		timeout = terminationTimeout
+	}
+	select {
+	case <-it.done:
+	case <-ctx.Done():
+	case <-c.clock().After(timeout):
+		c.Logger.Warnf("自动召唤超过%v未收到召唤结束,类型:%s,公共地址:%d", timeout, TypeIDName(key.typeID), key.commonAddr)
+	}
+}
+
+//Ping 发送测试激活帧并等待测试确认帧,返回往返时间
+func (c *Client) Ping(ctx context.Context) (time.Duration, error) {
+	ch := make(chan struct{})
+	c.mu.Lock()
+	connCtx := c.connCtx
+	if connCtx == nil || connCtx.Err() != nil {
+		c.mu.Unlock()
+		return 0, ErrNotConnected
+	}
+	c.pingChans = append(c.pingChans, ch)
+	c.mu.Unlock()
+	start := c.clock().Now()
+	c.sendUFrame(testFrAct)
+	select {
+	case <-ch:
+		return c.clock().Now().Sub(start), nil
+	case <-ctx.Done():
+		c.removePingChan(ch)
+		return 0, ctx.Err()
+	case <-connCtx.Done():
+		c.removePingChan(ch)
+		return 0, ErrNotConnected
+	}
+}
+
+//removePingChan 移除等待测试确认帧的Ping
+func (c *Client) removePingChan(ch chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, pingChan := range c.pingChans {
+		if pingChan == ch {
+			c.pingChans = append(c.pingChans[:i], c.pingChans[i+1:]...)
+			return
+		}
+	}
+}
+
+//send 将数据放入发送队列,连接断开时丢弃
+func (c *Client) send(data []byte) {
+	c.mu.Lock()
+	ctx := c.connCtx
+	c.mu.Unlock()
+	if ctx == nil {
+		return
+	}
+	select {
+	case c.sendChan <- data:
+	case <-ctx.Done():
+	}
+}
+
+//IFrameNum 当前连接收到的数据I帧数量,重连后从0开始
+func (c *Client) IFrameNum() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.iFrameNum
+}
+
+//connected 当前是否已建立连接
+func (c *Client) connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connCtx != nil && c.connCtx.Err() == nil
+}
+
+//dataStale 超过StaleDataTimeout未收到数据时返回true,并重新开始计时
+func (c *Client) dataStale() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock().Now()
+	if now.Sub(c.lastData) < c.Config.StaleDataTimeout {
+		return false
+	}
+	c.lastData = now
+	return true
+}
+
+//setPeerAck 记录对端确认的接收序号,确认了未发送的I帧时不记录
+func (c *Client) setPeerAck(recv int16, apdu *APDU) {
+	c.mu.Lock()
+	ssn := c.ssn
+	valid := (int(recv)-int(c.peerAck)+1<<15)%(1<<15) <= c.outstanding()
+	if valid && recv != c.peerAck {
+		n := (int(recv) - int(c.peerAck) + 1<<15) % (1 << 15)
+		if n > len(c.unackedSent) {
+			n = len(c.unackedSent)
+		}
+		c.unackedSent = c.unackedSent[n:]
+		c.peerAck = recv
+		c.saveSeq()
+		c.grantSend()
+	}
+	c.mu.Unlock()
+	if !valid {
+		c.seqError(SeqErrorDetail{Kind: SeqErrorAck, Expected: uint16(ssn), Received: uint16(recv), Frame: apdu.Raw})
+	}
+}
+
+//oldestUnacked 最早一个已发送未被确认的I帧距今的时间,没有时ok为false
+func (c *Client) oldestUnacked() (age time.Duration, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.unackedSent) == 0 {
+		return 0, false
+	}
+	return c.clock().Now().Sub(c.unackedSent[0]), true
 }
 
 //incrRsn 增加rsn
 func (c *Client) incrRsn() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.rsn++
 	if c.rsn < 0 {
 		c.rsn = 0
 	}
+	c.saveSeq()
 }
 
-//Close 结束程序
+//incrSsn 增加ssn,调用方需持有mu
+func (c *Client) incrSsn() {
+	c.ssn++
+	if c.ssn < 0 {
+		c.ssn = 0
+	}
+}
+
+//handleSignal 收到退出信号时关闭客户端
 func (c *Client) handleSignal() {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Kill, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-	<-signals
-	c.cancel()
-	c.wg.Wait()
-	if c.conn != nil {
-		c.conn.Close()
+	defer signal.Stop(signals)
+	select {
+	case <-signals:
+		c.Close()
+	case <-c.ctx.Done():
 	}
-	c.Logger.Println("断开服务器连接，程序关闭")
-	os.Exit(0)
 }
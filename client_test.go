@@ -0,0 +1,2627 @@
+package iec104
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestServer(t *testing.T) *TestServer {
+	s, err := NewTestServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+//nextIFrame 跳过S帧和U帧,返回下一个类型为typeID的I帧
+func (s *TestServer) nextIFrame(t *testing.T, typeID byte) []byte {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case frame := <-s.frames:
+			if frame[2]&1 == iFrame && frame[6] == typeID {
+				return frame
+			}
+		case <-timeout:
+			t.Fatalf("等待类型为%d的I帧超时", typeID)
+			return nil
+		}
+	}
+}
+
+//sendIFrameWithAck 以I帧发送asdu,接收序号为rsn而不是已收到的I帧数量
+func (s *TestServer) sendIFrameWithAck(asdu []byte, rsn int16) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.Write(encodeIFrame(s.ssn, rsn, asdu))
+	s.ssn++
+}
+
+//newTestClient 创建连接到s的客户端,测试结束时关闭
+func newTestClient(t *testing.T, s *TestServer, config Config) *Client {
+	return newTestClientWithTask(t, s, config, func(*APDU) {})
+}
+
+//newTestClientWithTask 创建连接到s并以task处理数据的客户端,测试结束时关闭
+func newTestClientWithTask(t *testing.T, s *TestServer, config Config, task func(*APDU)) *Client {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	c := NewClient(s.Addr(), logger)
+	c.Config = config
+	done := make(chan struct{})
+	go func() {
+		c.Run(task)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		c.Close()
+		<-done
+	})
+	s.nextIFrame(t, CIcNa1)
+	return c
+}
+
+//endStationCall 回复连接后自动发送的总召唤的召唤结束,之后Interrogate才会向该公共地址发送召唤
+func endStationCall(t *testing.T, s *TestServer, c *Client, commonAddr uint16) {
+	t.Helper()
+	key := interrogationKey{commonAddr, CIcNa1}
+	c.mu.Lock()
+	it, ok := c.interrogations[key]
+	c.mu.Unlock()
+	if !ok {
+		t.Fatalf("公共地址%d没有自动召唤", commonAddr)
+	}
+	ca := parseLittleEndianUInt16(commonAddr)
+	s.SendIFrame([]byte{CIcNa1, 0x01, 0x0a, 0x00, ca[0], ca[1], 0x00, 0x00, 0x00, it.qualifier})
+	select {
+	case <-it.finished:
+	case <-time.After(2 * time.Second):
+		t.Fatal("召唤结束后自动召唤未结束")
+	}
+}
+
+func TestClient_SendCommandTimeout(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{Clock: clock})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.SendSingleCommand(1, 1, true, false)
+	}()
+	s.nextIFrame(t, CScNa1)
+	//总召唤定时器、t3定时器、t1定时器、自动总召唤结束定时器、命令锁和命令确认的超时定时器
+	if !clock.waitTimers(6, time.Second) {
+		t.Fatal("等待命令超时定时器超时")
+	}
+	clock.Advance(commandTimeout)
+	select {
+	case err := <-errc:
+		if err != ErrCommandTimeout {
+			t.Errorf("Client.SendSingleCommand() error = %v, want %v", err, ErrCommandTimeout)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("模拟时钟前进后命令未超时")
+	}
+}
+
+func TestClient_InterrogateEmpty(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	endStationCall(t, s, c, 1)
+	type result struct {
+		signals []*Signal
+		err     error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		signals, err := c.Interrogate(context.Background(), 1, QoiGroup1)
+		resc <- result{signals, err}
+	}()
+	frame := s.nextIFrame(t, CIcNa1)
+	//只回复召唤确认和召唤结束,不上送数据
+	asdu := append([]byte{}, frame[6:]...)
+	asdu[2] = 7
+	s.SendIFrame(asdu)
+	asdu = append([]byte{}, frame[6:]...)
+	asdu[2] = 10
+	s.SendIFrame(asdu)
+	select {
+	case res := <-resc:
+		if res.err != nil {
+			t.Fatalf("Client.Interrogate() error = %v", res.err)
+		}
+		if res.signals == nil || len(res.signals) != 0 {
+			t.Errorf("Client.Interrogate() = %v, want empty slice", res.signals)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("召唤结束后Interrogate未返回")
+	}
+}
+
+func TestClient_InterrogationTimeout(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{InterrogationTimeout: 200 * time.Millisecond})
+	type result struct {
+		signals []*Signal
+		err     error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		signals, err := c.Interrogate(context.Background(), 1, QoiGroup1)
+		resc <- result{signals, err}
+	}()
+	frame := s.nextIFrame(t, CIcNa1)
+	//回复召唤确认和1个信息对象,不发送召唤结束
+	asdu := append([]byte{}, frame[6:]...)
+	asdu[2] = 7
+	s.SendIFrame(asdu)
+	s.SendIFrame([]byte{0x01, 0x01, 0x15, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+	select {
+	case res := <-resc:
+		if !errors.Is(res.err, ErrInterrogationIncomplete) {
+			t.Errorf("Client.Interrogate() error = %v, want %v", res.err, ErrInterrogationIncomplete)
+		}
+		if len(res.signals) != 1 || res.signals[0].Address != 1 {
+			t.Errorf("Client.Interrogate() = %v, want 已收到的1个信息对象", res.signals)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("超过InterrogationTimeout后Interrogate未返回")
+	}
+	//超时后同一公共地址可以再次召唤
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Interrogate(ctx, 1, QoiGroup1)
+	s.nextIFrame(t, CIcNa1)
+}
+
+func TestClient_PendingCommands(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.SendSingleCommand(1, 0x6001, true, false)
+	}()
+	frame := s.nextIFrame(t, CScNa1)
+	commands := c.PendingCommands()
+	if len(commands) != 1 || commands[0].IOA != 0x6001 || commands[0].State != CommandExecuting {
+		t.Fatalf("Client.PendingCommands() = %v, want executing command", commands)
+	}
+	asdu := append([]byte{}, frame[6:]...)
+	asdu[2] = 7
+	s.SendIFrame(asdu)
+	if err := <-errc; err != nil {
+		t.Fatalf("Client.SendSingleCommand() error = %v", err)
+	}
+	commands = c.PendingCommands()
+	if len(commands) != 1 || commands[0].State != CommandAwaitingTermination {
+		t.Fatalf("Client.PendingCommands() = %v, want awaiting-termination command", commands)
+	}
+	asdu = append([]byte{}, frame[6:]...)
+	asdu[2] = 10
+	s.SendIFrame(asdu)
+	deadline := time.Now().Add(2 * time.Second)
+	for len(c.PendingCommands()) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("激活终止后命令仍未完成")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClient_SendCommandAwaitingTermination(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.SendSingleCommand(1, 0x6001, true, false)
+	}()
+	first := append([]byte{}, s.nextIFrame(t, CScNa1)[6:]...)
+	first[2] = 7
+	s.SendIFrame(first)
+	if err := <-errc; err != nil {
+		t.Fatalf("Client.SendSingleCommand() error = %v", err)
+	}
+	go func() {
+		errc <- c.SendSingleCommand(1, 0x6001, false, false)
+	}()
+	//第一条命令的激活终止到达前,同一信息对象的第二条命令不能发送
+	time.Sleep(100 * time.Millisecond)
+	commands := c.PendingCommands()
+	if len(commands) != 1 || commands[0].State != CommandAwaitingTermination {
+		t.Fatalf("Client.PendingCommands() = %v, want first command awaiting termination", commands)
+	}
+	term := append([]byte{}, first...)
+	term[2] = 10
+	s.SendIFrame(term)
+	second := append([]byte{}, s.nextIFrame(t, CScNa1)[6:]...)
+	if second[len(second)-1] != 0x00 {
+		t.Fatalf("发送的命令限定词 = %#x, want 0x00", second[len(second)-1])
+	}
+	second[2] = 7
+	s.SendIFrame(second)
+	if err := <-errc; err != nil {
+		t.Fatalf("Client.SendSingleCommand() error = %v", err)
+	}
+	commands = c.PendingCommands()
+	if len(commands) != 1 || commands[0].State != CommandAwaitingTermination {
+		t.Fatalf("Client.PendingCommands() = %v, want second command awaiting termination", commands)
+	}
+	second[2] = 10
+	s.SendIFrame(second)
+	deadline := time.Now().Add(2 * time.Second)
+	for len(c.PendingCommands()) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("激活终止后命令仍未完成")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClient_DeactivateCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		cause       byte
+		wantErr     error
+		wantPending int
+	}{
+		{"测试肯定的停止激活确认", byte(CauseDeactivationCon), nil, 0},
+		{"测试否定的停止激活确认", byte(CauseDeactivationCon) | 0x40, ErrCommandRejected, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			c := newTestClient(t, s, Config{})
+			if err := c.DeactivateCommand(1, 0x6001); err != ErrNoPendingCommand {
+				t.Fatalf("Client.DeactivateCommand() error = %v, want %v", err, ErrNoPendingCommand)
+			}
+			errc := make(chan error, 1)
+			go func() {
+				errc <- c.SendSingleCommand(1, 0x6001, true, false)
+			}()
+			con := append([]byte{}, s.nextIFrame(t, CScNa1)[6:]...)
+			con[2] = byte(CauseActivationCon)
+			s.SendIFrame(con)
+			if err := <-errc; err != nil {
+				t.Fatalf("Client.SendSingleCommand() error = %v", err)
+			}
+			deact := make(chan error, 1)
+			go func() {
+				deact <- c.DeactivateCommand(1, 0x6001)
+			}()
+			frame := append([]byte{}, s.nextIFrame(t, CScNa1)[6:]...)
+			if Cause(frame[2]) != CauseDeactivation {
+				t.Fatalf("撤销命令的传输原因 = %d, want %d", frame[2], CauseDeactivation)
+			}
+			frame[2] = tt.cause
+			s.SendIFrame(frame)
+			if err := <-deact; err != tt.wantErr {
+				t.Errorf("Client.DeactivateCommand() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := len(c.PendingCommands()); got != tt.wantPending {
+				t.Errorf("len(Client.PendingCommands()) = %d, want %d", got, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestClient_Ping(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   bool
+		wantErr error
+	}{
+		{"测试收到测试确认帧", true, nil},
+		{"测试等待测试确认帧超时", false, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			c := newTestClient(t, s, Config{})
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			errc := make(chan error, 1)
+			go func() {
+				_, err := c.Ping(ctx)
+				errc <- err
+			}()
+			timeout := time.After(2 * time.Second)
+			for sent := false; !sent; {
+				select {
+				case frame := <-s.frames:
+					sent = bytes.Equal(frame[2:6], testFrAct[:])
+				case <-timeout:
+					t.Fatal("Ping未发送测试激活帧")
+				}
+			}
+			if tt.reply {
+				s.mu.Lock()
+				s.conn.Write(encodeUFrame(testFrCon))
+				s.mu.Unlock()
+			}
+			if err := <-errc; err != tt.wantErr {
+				t.Errorf("Client.Ping() error = %v, want %v", err, tt.wantErr)
+			}
+			c.mu.Lock()
+			pings := len(c.pingChans)
+			c.mu.Unlock()
+			if pings != 0 {
+				t.Errorf("len(Client.pingChans) = %d, want 0", pings)
+			}
+		})
+	}
+}
+
+func TestClient_CloseFromTask(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"测试直接断开", Config{}},
+		{"测试发送剩余数据后断开", Config{FlushTimeout: time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			logger := logrus.New()
+			logger.Out = ioutil.Discard
+			c := NewClient(s.Addr(), logger)
+			c.Config = tt.config
+			done := make(chan struct{})
+			go func() {
+				c.Run(func(*APDU) { c.Close() })
+				close(done)
+			}()
+			s.nextIFrame(t, CIcNa1)
+			s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+			select {
+			case <-done:
+			case <-time.After(2 * time.Second):
+				t.Fatal("task中调用Close后Run未返回")
+			}
+			if c.ctx.Err() == nil {
+				t.Error("task中调用Close后客户端未关闭")
+			}
+		})
+	}
+}
+
+func TestClient_UndecodableIFrame(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{AckWindow: 2})
+	//格式正确但不支持的类型标识(M_DP_TB_1)仍推进接收序号并确认,不重新同步
+	s.SendIFrame([]byte{0x1f, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x14})
+	s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+	timeout := time.After(2 * time.Second)
+	for acked := false; !acked; {
+		select {
+		case frame := <-s.frames:
+			acked = frame[2]&3 == sFrame && binary.LittleEndian.Uint16(frame[4:6])>>1 == 2
+		case <-timeout:
+			t.Fatal("未确认无法解析的I帧")
+		}
+	}
+	if _, recvSeq, _, _ := c.SeqState(); recvSeq != 2 {
+		t.Errorf("Client.SeqState() recvSeq = %d, want 2", recvSeq)
+	}
+	if n := c.IFrameNum(); n != 1 {
+		t.Errorf("Client.IFrameNum() = %d, want 1", n)
+	}
+	if h := c.Health(); h.DecodeErrors != 1 || h.LastError == "" || h.Reconnects != 0 {
+		t.Errorf("Client.Health() = %+v, want 1 decode error, last error and no reconnect", h)
+	}
+}
+
+func TestClient_SendCommandNotActive(t *testing.T) {
+	//只建立连接,不回复启动确认帧
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			ioutil.ReadAll(conn)
+		}
+	}()
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	c := NewClient(ln.Addr().String(), logger)
+	done := make(chan struct{})
+	go func() {
+		c.Run(func(*APDU) {})
+		close(done)
+	}()
+	defer func() {
+		c.Close()
+		<-done
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for !c.connected() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待建立连接超时")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := c.SendSingleCommand(1, 1, true, false); err != ErrNotActive {
+		t.Errorf("Client.SendSingleCommand() error = %v, want %v", err, ErrNotActive)
+	}
+	if len(c.PendingCommands()) != 0 {
+		t.Error("发送失败的命令仍处于未完成状态")
+	}
+}
+
+func TestClient_AckPiggyback(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{Clock: clock})
+	asdu := []byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01}
+	//t2内没有I帧可发送,到期后发送S帧。总召唤定时器、t3定时器、自动总召唤的t1定时器和召唤结束定时器、t2定时器
+	s.SendIFrame(asdu)
+	if !clock.waitTimers(5, time.Second) {
+		t.Fatal("等待t2定时器超时")
+	}
+	clock.Advance(ackTimeout)
+	select {
+	case frame := <-s.frames:
+		if frame[2]&3 != sFrame || !bytes.Equal(frame[4:6], []byte{0x02, 0x00}) {
+			t.Fatalf("t2到期后收到[% X], want S帧 rsn=1", frame)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("t2到期后未发送S帧")
+	}
+	//t2内发送的I帧捎带确认,到期后不再发送S帧
+	s.SendIFrame(asdu)
+	if !clock.waitTimers(5, time.Second) {
+		t.Fatal("等待t2定时器超时")
+	}
+	go c.Interrogate(context.Background(), 2, QoiStation)
+	frame := s.nextIFrame(t, CIcNa1)
+	if !bytes.Equal(frame[4:6], []byte{0x04, 0x00}) {
+		t.Fatalf("召唤命令的接收序号为[% X], want rsn=2", frame[4:6])
+	}
+	clock.Advance(ackTimeout)
+	select {
+	case frame := <-s.frames:
+		t.Fatalf("捎带确认后仍收到[% X]", frame)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestClient_Health(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	h := c.Health()
+	if !h.Connected || !h.Active || h.Unacknowledged != 1 || h.Reconnects != 0 {
+		t.Fatalf("Client.Health() = %+v, want connected, active, 1 unacknowledged", h)
+	}
+	//对端I帧确认了总召唤命令
+	s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Health().Unacknowledged != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Client.Health() = %+v, want 0 unacknowledged", c.Health())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClient_SendWindowFromReadPath(t *testing.T) {
+	s := newTestServer(t)
+	//k为1,连接后的总召唤未被确认
+	newTestClient(t, s, Config{SendWindow: 1})
+	//不确认总召唤的初始化结束,读协程触发的总召唤等待发送窗口,不能阻塞读协程
+	s.sendIFrameWithAck([]byte{MEiNA1, 0x01, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00}, 0)
+	time.Sleep(50 * time.Millisecond)
+	s.SendSFrame()
+	s.nextIFrame(t, CIcNa1)
+}
+
+func TestClient_SendWindowTimeout(t *testing.T) {
+	s := newTestServer(t)
+	//从站不确认总召唤,k窗口已满
+	c := newTestClient(t, s, Config{SendWindow: 1})
+	err := c.SendCommand(Command{TypeID: CScNa1, CommonAddr: 1, IOA: 1, Value: []byte{0x01}, Timeout: 100 * time.Millisecond})
+	if err != ErrCommandTimeout {
+		t.Fatalf("Client.SendCommand() error = %v, want %v", err, ErrCommandTimeout)
+	}
+	if commands := c.PendingCommands(); len(commands) != 0 {
+		t.Errorf("Client.PendingCommands() = %v, want empty", commands)
+	}
+	//确认后超时的命令不再发送,后续命令照常发送
+	s.SendSFrame()
+	go c.SendSingleCommand(1, 2, true, false)
+	frame := s.nextIFrame(t, CScNa1)
+	if ioa := frame[12]; ioa != 2 {
+		t.Errorf("发送的命令信息对象地址 = %d, want 2", ioa)
+	}
+}
+
+func TestClient_AckTimeout(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{Clock: clock})
+	//总召唤定时器、t3定时器、总召唤命令的t1定时器和召唤结束定时器
+	if !clock.waitTimers(4, time.Second) {
+		t.Fatal("等待t1定时器超时")
+	}
+	clock.Advance(confirmTimeout)
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Health().LastError != ErrAckTimeout.Error() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Client.Health() = %+v, want last error %v", c.Health(), ErrAckTimeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	//断开后重新连接并发送总召唤
+	s.nextIFrame(t, CIcNa1)
+}
+
+func TestClient_RemoteAddr(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	if addr := c.RemoteAddr(); addr == nil || addr.String() != s.Addr() {
+		t.Errorf("Client.RemoteAddr() = %v, want %v", addr, s.Addr())
+	}
+	s.mu.Lock()
+	remote := s.conn.RemoteAddr().String()
+	s.mu.Unlock()
+	if addr := c.LocalAddr(); addr == nil || addr.String() != remote {
+		t.Errorf("Client.LocalAddr() = %v, want %v", addr, remote)
+	}
+	c.Close()
+	if addr := c.RemoteAddr(); addr != nil {
+		t.Errorf("关闭后Client.RemoteAddr() = %v, want nil", addr)
+	}
+	if addr := c.LocalAddr(); addr != nil {
+		t.Errorf("关闭后Client.LocalAddr() = %v, want nil", addr)
+	}
+}
+
+func TestClient_FreezeAndReadCounters(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	type result struct {
+		signals []*Signal
+		err     error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		signals, err := c.FreezeAndReadCounters(context.Background(), 1, RqtGroup1)
+		resc <- result{signals, err}
+	}()
+	reply := func(frame []byte, cause byte) {
+		asdu := append([]byte{}, frame[6:]...)
+		asdu[2] = cause
+		s.SendIFrame(asdu)
+	}
+	//冻结带复位
+	frame := s.nextIFrame(t, CCiNa1)
+	if frame[len(frame)-1] != RqtGroup1|FrzFreezeReset {
+		t.Fatalf("冻结命令限定词为%#x, want %#x", frame[len(frame)-1], RqtGroup1|FrzFreezeReset)
+	}
+	reply(frame, 7)
+	reply(frame, 10)
+	//读冻结值
+	frame = s.nextIFrame(t, CCiNa1)
+	if frame[len(frame)-1] != RqtGroup1|FrzRead {
+		t.Fatalf("读命令限定词为%#x, want %#x", frame[len(frame)-1], RqtGroup1|FrzRead)
+	}
+	reply(frame, 7)
+	s.SendIFrame([]byte{0x0f, 0x01, 38, 0x00, 0x01, 0x00, 0x01, 0x64, 0x00, 0xfe, 0x12, 0x00, 0x00, 0x01})
+	reply(frame, 10)
+	select {
+	case res := <-resc:
+		if res.err != nil {
+			t.Fatalf("Client.FreezeAndReadCounters() error = %v", res.err)
+		}
+		if len(res.signals) != 1 || res.signals[0].Value != 0x12fe {
+			t.Errorf("Client.FreezeAndReadCounters() = %v, want 1 counter", res.signals)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("计数量召唤结束后FreezeAndReadCounters未返回")
+	}
+}
+
+func TestClient_ReadCounters(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	type result struct {
+		signals []*Signal
+		err     error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		signals, err := c.ReadCounters(context.Background(), 1, RqtGroup2)
+		resc <- result{signals, err}
+	}()
+	frame := s.nextIFrame(t, CCiNa1)
+	if frame[len(frame)-1] != RqtGroup2|FrzRead {
+		t.Fatalf("命令限定词为%#x, want %#x", frame[len(frame)-1], RqtGroup2|FrzRead)
+	}
+	asdu := append([]byte{}, frame[6:]...)
+	asdu[2] = 7
+	s.SendIFrame(asdu)
+	//第2组计数量的传输原因为39,总请求的计数量不计入
+	s.SendIFrame([]byte{0x0f, 0x01, 37, 0x00, 0x01, 0x00, 0x01, 0x64, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01})
+	s.SendIFrame([]byte{0x0f, 0x01, 39, 0x00, 0x01, 0x00, 0x02, 0x64, 0x00, 0xfe, 0x12, 0x00, 0x00, 0x01})
+	asdu[2] = 10
+	s.SendIFrame(asdu)
+	select {
+	case res := <-resc:
+		if res.err != nil {
+			t.Fatalf("Client.ReadCounters() error = %v", res.err)
+		}
+		if len(res.signals) != 1 || res.signals[0].Address != 0x6402 {
+			t.Errorf("Client.ReadCounters() = %v, want 第2组的1个计数量", res.signals)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("计数量召唤结束后ReadCounters未返回")
+	}
+	if _, err := c.ReadCounters(context.Background(), 1, 6); err == nil {
+		t.Error("Client.ReadCounters() 请求6 error = nil, want 非法")
+	}
+}
+
+func TestClient_ResyncStrayByte(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	s.mu.Lock()
+	s.conn.Write([]byte{0x00})
+	s.mu.Unlock()
+	s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+	deadline := time.Now().Add(2 * time.Second)
+	for c.IFrameNum() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("多出1个字节后未重新同步")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if h := c.Health(); h.Reconnects != 0 {
+		t.Errorf("Client.Health() = %+v, want no reconnect", h)
+	}
+}
+
+func TestClient_SendCommandTimeoutFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration //Command.Timeout
+		want    time.Duration
+	}{
+		{"测试按类型配置的超时时间", 0, time.Second},
+		{"测试单次命令的超时时间", 30 * time.Second, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			s := newTestServer(t)
+			//t1大于命令超时时间,命令超时前不因I帧未被确认断开
+			c := newTestClient(t, s, Config{Clock: clock, ConfirmTimeout: time.Minute, CommandTimeouts: map[byte]time.Duration{CScNa1: time.Second}})
+			errc := make(chan error, 1)
+			go func() {
+				errc <- c.SendCommand(Command{TypeID: CScNa1, CommonAddr: 1, IOA: 1, Value: []byte{0x01}, Timeout: tt.timeout})
+			}()
+			s.nextIFrame(t, CScNa1)
+			if !clock.waitTimers(6, time.Second) {
+				t.Fatal("等待命令超时定时器超时")
+			}
+			clock.Advance(tt.want - time.Millisecond)
+			select {
+			case err := <-errc:
+				t.Fatalf("超时前命令返回 %v", err)
+			case <-time.After(50 * time.Millisecond):
+			}
+			clock.Advance(time.Millisecond)
+			select {
+			case err := <-errc:
+				if err != ErrCommandTimeout {
+					t.Errorf("Client.SendCommand() error = %v, want %v", err, ErrCommandTimeout)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("模拟时钟前进后命令未超时")
+			}
+		})
+	}
+}
+
+func TestClient_OnInterrogationComplete(t *testing.T) {
+	s := newTestServer(t)
+	completed := make(chan uint16, 1)
+	newTestClient(t, s, Config{
+		OnInterrogationComplete:  func(commonAddr uint16) { completed <- commonAddr },
+		SkipCounterInterrogation: true,
+	})
+	s.SendIFrame([]byte{0x64, 0x01, 0x0a, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x14})
+	select {
+	case commonAddr := <-completed:
+		if commonAddr != 2 {
+			t.Errorf("OnInterrogationComplete() commonAddr = %d, want 2", commonAddr)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("收到总召唤结束后未调用OnInterrogationComplete")
+	}
+	//不发送电度总召唤
+	select {
+	case frame := <-s.frames:
+		if frame[2]&1 == iFrame && frame[6] == CCiNa1 {
+			t.Errorf("SkipCounterInterrogation为true时仍发送电度总召唤: [% X]", frame)
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+//blockingConn 对端不读取数据的连接,Write阻塞直至写超时
+type blockingConn struct {
+	net.Conn
+	mu       sync.Mutex
+	deadline time.Time
+	closed   chan struct{}
+}
+
+func (b *blockingConn) SetWriteDeadline(t time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deadline = t
+	return nil
+}
+
+func (b *blockingConn) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	deadline := b.deadline
+	b.mu.Unlock()
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timeout = time.After(time.Until(deadline))
+	}
+	select {
+	case <-timeout:
+		return 0, errors.New("写超时")
+	case <-b.closed:
+		return 0, errors.New("连接已关闭")
+	}
+}
+
+func (b *blockingConn) Close() error {
+	close(b.closed)
+	return nil
+}
+
+func TestClient_WriteTimeout(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	c := NewClient("127.0.0.1:0", logger)
+	c.Config.WriteTimeout = 50 * time.Millisecond
+	conn := &blockingConn{closed: make(chan struct{})}
+	defer conn.Close()
+	c.conn = conn
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.connCtx, c.cancel = ctx, cancel
+	c.wg.Add(1)
+	go c.write(ctx)
+	c.sendChan <- convertBytes(testFrAct[:])
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("写超时后未断开连接")
+	}
+	c.wg.Wait()
+	if h := c.Health(); h.LastError == "" {
+		t.Error("Client.Health() 未记录写超时")
+	}
+}
+
+func TestClient_FlushTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		blocked bool
+	}{
+		{"测试发送队列清空后断开", false},
+		{"测试对端不读取时超时后断开", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			logger := logrus.New()
+			logger.Out = ioutil.Discard
+			c := NewClient("127.0.0.1:0", logger)
+			c.Config.Clock = clock
+			c.Config.FlushTimeout = 5 * time.Second
+			c.Config.WriteTimeout = time.Minute
+			blocking := &blockingConn{closed: make(chan struct{})}
+			local, remote := net.Pipe()
+			defer local.Close()
+			defer remote.Close()
+			c.conn = local
+			if tt.blocked {
+				c.conn = blocking
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			c.connCtx, c.cancel = ctx, cancel
+			ack := encodeSFrame(1)
+			if tt.blocked {
+				c.wg.Add(1)
+				go c.write(ctx)
+				//写协程阻塞在第一帧,确认帧留在发送队列中
+				c.sendChan <- convertBytes(testFrAct[:])
+				c.sendChan <- ack
+				c.Close()
+				if !clock.waitTimers(1, time.Second) {
+					t.Fatal("等待FlushTimeout定时器超时")
+				}
+				select {
+				case <-c.ctx.Done():
+					t.Fatal("FlushTimeout到期前关闭了客户端")
+				case <-time.After(50 * time.Millisecond):
+				}
+				clock.Advance(5 * time.Second)
+			} else {
+				c.sendChan <- ack
+				c.Close()
+				c.wg.Add(1)
+				go c.write(ctx)
+				//对端读取确认帧之前不关闭客户端
+				select {
+				case <-c.ctx.Done():
+					t.Fatal("发送队列清空前关闭了客户端")
+				case <-time.After(50 * time.Millisecond):
+				}
+				frame, err := ReadFrame(remote)
+				if err != nil || !bytes.Equal(frame, ack) {
+					t.Errorf("关闭前发送的帧 = [% X], %v, want [% X]", frame, err, ack)
+				}
+			}
+			select {
+			case <-c.ctx.Done():
+			case <-time.After(2 * time.Second):
+				t.Fatal("Close后未关闭客户端")
+			}
+			cancel()
+			blocking.Close()
+			c.wg.Wait()
+		})
+	}
+}
+
+func TestClient_sendTotalCall(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   []byte
+	}{
+		{"测试默认公共地址", Config{}, []byte{0x64, 0x01, 0x06, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x14}},
+		{"测试配置公共地址和源发地址", Config{CommonAddr: 0x0203, Originator: 5}, []byte{0x64, 0x01, 0x06, 0x05, 0x03, 0x02, 0x00, 0x00, 0x00, 0x14}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			newTestClient(t, s, tt.config)
+			//初始化结束后重新发送总召唤
+			s.SendIFrame([]byte{0x46, 0x01, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00})
+			frame := s.nextIFrame(t, CIcNa1)
+			if !bytes.Equal(frame[6:], tt.want) {
+				t.Errorf("总召唤ASDU = [% X], want [% X]", frame[6:], tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_SendClockSync(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	now := time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.Local)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.SendClockSync(1, now)
+	}()
+	frame := s.nextIFrame(t, CCsNa1)
+	//信息对象地址为3个字节的0,之后为7个字节时标
+	want := append([]byte{CCsNa1, 0x01, 0x06, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}, encodeCP56Time2a(now)...)
+	if !bytes.Equal(frame[6:], want) {
+		t.Fatalf("时钟同步ASDU = [% X], want [% X]", frame[6:], want)
+	}
+	asdu := append([]byte{}, frame[6:]...)
+	asdu[2] = 7
+	s.SendIFrame(asdu)
+	if err := <-errc; err != nil {
+		t.Fatalf("Client.SendClockSync() error = %v", err)
+	}
+	//时钟同步没有激活终止
+	if commands := c.PendingCommands(); len(commands) != 0 {
+		t.Errorf("Client.PendingCommands() = %v, want empty", commands)
+	}
+}
+
+func TestClient_StaleDataTimeout(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestServer(t)
+	//从站不确认总召唤,t1大于测试中前进的时间
+	c := newTestClient(t, s, Config{Clock: clock, StaleDataTimeout: time.Minute, ConfirmTimeout: 10 * time.Minute})
+	//总召唤定时器、t3定时器、t1定时器、自动总召唤结束定时器和数据超时检查定时器
+	if !clock.waitTimers(5, time.Second) {
+		t.Fatal("等待数据超时检查定时器超时")
+	}
+	clock.Advance(time.Minute)
+	s.nextIFrame(t, CIcNa1)
+	//收到数据后重新计时
+	clock.Advance(30 * time.Second)
+	s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+	deadline := time.Now().Add(2 * time.Second)
+	for c.IFrameNum() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("等待处理数据超时")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	clock.Advance(30 * time.Second)
+	timeout := time.After(100 * time.Millisecond)
+	for {
+		select {
+		case frame := <-s.frames:
+			if frame[2]&1 == iFrame && frame[6] == CIcNa1 {
+				t.Fatalf("收到数据后仍重新发送总召唤: [% X]", frame)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func TestClient_CommandRate(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{Clock: clock, CommandRate: 1, RejectRateLimited: true})
+	go c.SendSingleCommand(1, 1, true, false)
+	s.nextIFrame(t, CScNa1)
+	if err := c.SendSingleCommand(1, 2, true, false); err != ErrRateLimited {
+		t.Errorf("Client.SendSingleCommand() error = %v, want %v", err, ErrRateLimited)
+	}
+	//召唤不受限制
+	go c.Interrogate(context.Background(), 2, QoiGroup1)
+	s.nextIFrame(t, CIcNa1)
+	clock.Advance(time.Second)
+	go c.SendSingleCommand(1, 3, true, false)
+	s.nextIFrame(t, CScNa1)
+}
+
+func TestClient_OnSignal(t *testing.T) {
+	s := newTestServer(t)
+	signals := make(chan *Signal, 2)
+	newTestClient(t, s, Config{OnSignal: func(commonAddr uint16, sig *Signal) {
+		if commonAddr == 2 {
+			signals <- sig
+		}
+	}})
+	s.SendIFrame([]byte{0x01, 0x02, 0x03, 0x00, 0x02, 0x00, 0x01, 0x00, 0x00, 0x01, 0x02, 0x00, 0x00, 0x00})
+	for _, want := range []Signal{{TypeID: 1, Address: 1, Value: 1, Cause: 3}, {TypeID: 1, Address: 2, Value: 0, Cause: 3}} {
+		select {
+		case got := <-signals:
+			if *got != want {
+				t.Errorf("OnSignal() = %+v, want %+v", *got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("收到数据后未调用OnSignal")
+		}
+	}
+}
+
+func TestClient_OnProtectionEvent(t *testing.T) {
+	s := newTestServer(t)
+	type event struct {
+		commonAddr uint16
+		ioa        uint32
+		event      ProtectionEvent
+	}
+	events := make(chan event, 2)
+	received := make(chan byte, 2)
+	newTestClientWithTask(t, s, Config{OnProtectionEvent: func(commonAddr uint16, sig *Signal, e *ProtectionEvent) {
+		events <- event{commonAddr, sig.Address, *e}
+	}}, func(apdu *APDU) { received <- apdu.ASDU.TypeID })
+	//单点遥信不调用OnProtectionEvent
+	s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x02, 0x00, 0x01, 0x00, 0x00, 0x01})
+	s.SendIFrame([]byte{0x26, 0x01, 0x03, 0x00, 0x02, 0x00, 0x01, 0x10, 0x00, 0x0a, 0xe8, 0x03, 0xD3, 0x42, 0x3B, 0x0E, 0x06, 0x0B, 0x13})
+	want := event{2, 0x1001, ProtectionEvent{Event: 2, Quality: 0x08, Elapsed: time.Second}}
+	select {
+	case got := <-events:
+		if got != want {
+			t.Errorf("OnProtectionEvent() = %+v, want %+v", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("收到继电保护设备事件后未调用OnProtectionEvent")
+	}
+	//之后仍交给task处理
+	for _, typeID := range []byte{MSpNa1, MEpTd1} {
+		select {
+		case got := <-received:
+			if got != typeID {
+				t.Errorf("task收到的类型 = %d, want %d", got, typeID)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("task未收到类型%d的帧", typeID)
+		}
+	}
+	select {
+	case got := <-events:
+		t.Errorf("OnProtectionEvent() 多余调用 %+v", got)
+	default:
+	}
+}
+
+func TestClient_AckSlowConsumer(t *testing.T) {
+	const n = 500
+	//task阻塞在第1帧,队列中最多缓存DataBuffer帧
+	tests := []struct {
+		name        string
+		config      Config
+		wantDropped int
+		wantFirst   uint32 //task收到的第2帧,第1帧总是1
+		want        int    //task收到的帧数
+	}{
+		{"测试默认配置不阻塞确认", Config{}, 0, 2, n},
+		{"测试丢弃最早的数据", Config{DataBuffer: 10, DataOverflow: OverflowDropOldest}, n - 11, n - 9, 11},
+		{"测试丢弃最新的数据", Config{DataBuffer: 10, DataOverflow: OverflowDropNewest}, n - 11, 2, 11},
+		{"测试队列足够时阻塞不丢弃", Config{DataBuffer: n, DataOverflow: OverflowBlock}, 0, 2, n},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			release := make(chan struct{})
+			var once sync.Once
+			got := make(chan uint32, n)
+			config := tt.config
+			config.AckTimeout = 50 * time.Millisecond
+			config.SkipCounterInterrogation = true
+			c := newTestClientWithTask(t, s, config, func(apdu *APDU) {
+				<-release
+				got <- apdu.Signals[0].Address
+			})
+			//Cleanup按注册的逆序执行,先释放task再关闭客户端
+			t.Cleanup(func() { once.Do(func() { close(release) }) })
+			for i := 0; i < n; i++ {
+				ioa := parseLittleEndianUInt16(uint16(i + 1))
+				s.SendIFrame([]byte{0x01, 0x01, 0x14, 0x00, 0x01, 0x00, ioa[0], ioa[1], 0x00, 0x01})
+			}
+			timeout := time.After(5 * time.Second)
+			for acked := false; !acked; {
+				select {
+				case frame := <-s.frames:
+					acked = frame[2]&3 == sFrame && int(binary.LittleEndian.Uint16(frame[4:6])>>1) == n
+				case <-timeout:
+					t.Fatalf("task阻塞时未确认全部%d个I帧", n)
+				}
+			}
+			deadline := time.Now().Add(2 * time.Second)
+			for c.Health().DroppedFrames != tt.wantDropped {
+				if time.Now().After(deadline) {
+					t.Fatalf("Client.Health().DroppedFrames = %d, want %d", c.Health().DroppedFrames, tt.wantDropped)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			once.Do(func() { close(release) })
+			for i := 0; i < tt.want; i++ {
+				want := uint32(1)
+				if i > 0 {
+					want = tt.wantFirst + uint32(i-1)
+				}
+				select {
+				case ioa := <-got:
+					if ioa != want {
+						t.Fatalf("task()第%d帧 ioa = %d, want %d", i+1, ioa, want)
+					}
+				case <-time.After(2 * time.Second):
+					t.Fatalf("task收到%d帧, want %d", i, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_SendCommandFromTask(t *testing.T) {
+	s := newTestServer(t)
+	errc := make(chan error, 1)
+	var c *Client
+	//c赋值后task才使用
+	ready := make(chan struct{})
+	c = newTestClientWithTask(t, s, Config{}, func(apdu *APDU) {
+		<-ready
+		if apdu.Signals[0].Address == 1 {
+			errc <- c.SendSingleCommand(1, 0x6001, true, false)
+		}
+	})
+	close(ready)
+	s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+	frame := s.nextIFrame(t, CScNa1)
+	//task等待激活确认期间继续收到数据,读协程不阻塞
+	for i := 0; i < 10; i++ {
+		s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x01})
+	}
+	asdu := append([]byte{}, frame[6:]...)
+	asdu[2] = 7
+	s.SendIFrame(asdu)
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("Client.SendSingleCommand() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("task中调用SendSingleCommand未返回")
+	}
+}
+
+func TestClient_UnexpectedCommonAddr(t *testing.T) {
+	tests := []struct {
+		name   string
+		reject bool
+		want   []uint16
+	}{
+		{"测试告警模式仍交给task", false, []uint16{2, 1}},
+		{"测试拒绝模式丢弃整帧", true, []uint16{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			unexpected := make(chan uint16, 1)
+			received := make(chan uint16, 2)
+			newTestClientWithTask(t, s, Config{
+				CommonAddrs:                []uint16{1},
+				RejectUnexpectedCommonAddr: tt.reject,
+				OnUnexpectedCommonAddr:     func(commonAddr uint16, apdu *APDU) { unexpected <- commonAddr },
+			}, func(apdu *APDU) { received <- apdu.ASDU.PublicAddress })
+			s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x02, 0x00, 0x01, 0x00, 0x00, 0x01})
+			s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+			select {
+			case commonAddr := <-unexpected:
+				if commonAddr != 2 {
+					t.Errorf("OnUnexpectedCommonAddr() commonAddr = %d, want 2", commonAddr)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("收到非预期的公共地址后未调用OnUnexpectedCommonAddr")
+			}
+			//task按接收顺序调用,丢弃的帧不会出现在公共地址1的帧之前
+			for _, want := range tt.want {
+				select {
+				case commonAddr := <-received:
+					if commonAddr != want {
+						t.Errorf("task收到的公共地址 = %d, want %d", commonAddr, want)
+					}
+				case <-time.After(2 * time.Second):
+					t.Fatalf("task未收到公共地址%d的帧", want)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_AllowedPoints(t *testing.T) {
+	s := newTestServer(t)
+	rejected := make(chan uint32, 1)
+	signals := make(chan uint32, 2)
+	c := newTestClient(t, s, Config{
+		AllowedPoints:   []PointAddr{{CommonAddr: 1, IOA: 1}},
+		OnRejectedPoint: func(commonAddr uint16, sig *Signal) { rejected <- sig.Address },
+		OnSignal:        func(commonAddr uint16, sig *Signal) { signals <- sig.Address },
+	})
+	//信息对象地址2不在白名单中,整帧丢弃
+	s.SendIFrame([]byte{0x01, 0x02, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x02, 0x00, 0x00, 0x01})
+	s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+	select {
+	case ioa := <-rejected:
+		if ioa != 2 {
+			t.Errorf("OnRejectedPoint() ioa = %d, want 2", ioa)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("收到白名单之外的信息对象后未调用OnRejectedPoint")
+	}
+	select {
+	case ioa := <-signals:
+		if ioa != 1 {
+			t.Errorf("OnSignal() ioa = %d, want 1", ioa)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("白名单中的信息对象未交给OnSignal")
+	}
+	if h := c.Health(); h.RejectedFrames != 1 {
+		t.Errorf("Client.Health().RejectedFrames = %d, want 1", h.RejectedFrames)
+	}
+}
+
+func TestClient_SendScaledSetpoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		echo    int16 //激活确认中回送的设定值
+		wantErr error
+	}{
+		{"测试回送值一致", 1000, nil},
+		{"测试从站限幅", 800, ErrSetpointMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			c := newTestClient(t, s, Config{})
+			type result struct {
+				applied int16
+				err     error
+			}
+			res := make(chan result, 1)
+			go func() {
+				applied, err := c.SendScaledSetpoint(1, 0x6201, 1000, 0, false)
+				res <- result{applied, err}
+			}()
+			frame := s.nextIFrame(t, CSeNb1)
+			if got := int16(binary.LittleEndian.Uint16(frame[15:17])); got != 1000 {
+				t.Fatalf("发送的设定值 = %d, want 1000", got)
+			}
+			asdu := append([]byte{}, frame[6:]...)
+			asdu[2] = 7
+			binary.LittleEndian.PutUint16(asdu[9:11], uint16(tt.echo))
+			s.SendIFrame(asdu)
+			select {
+			case r := <-res:
+				if r.err != tt.wantErr || r.applied != tt.echo {
+					t.Errorf("Client.SendScaledSetpoint() = %d, %v, want %d, %v", r.applied, r.err, tt.echo, tt.wantErr)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("收到激活确认后SendScaledSetpoint未返回")
+			}
+		})
+	}
+}
+
+func TestClient_TaskPanic(t *testing.T) {
+	s := newTestServer(t)
+	panics := make(chan interface{}, 1)
+	ioas := make(chan uint32, 1)
+	newTestClientWithTask(t, s, Config{OnTaskPanic: func(r interface{}, apdu *APDU) { panics <- r }}, func(apdu *APDU) {
+		if apdu.Signals[0].Address == 1 {
+			panic("task异常")
+		}
+		ioas <- apdu.Signals[0].Address
+	})
+	s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+	select {
+	case r := <-panics:
+		if r != "task异常" {
+			t.Errorf("OnTaskPanic() r = %v, want task异常", r)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("task发生panic后未调用OnTaskPanic")
+	}
+	s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x01})
+	select {
+	case ioa := <-ioas:
+		if ioa != 2 {
+			t.Errorf("task() ioa = %d, want 2", ioa)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("task发生panic后未继续处理数据")
+	}
+}
+
+func TestClient_ProtocolViolations(t *testing.T) {
+	frame := []byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01}
+	tests := []struct {
+		name   string
+		inject func(s *TestServer)
+		want   int //收到的数据I帧数量
+	}{
+		{"测试长度非法", func(s *TestServer) { s.InjectBadLength() }, 1},
+		{"测试重复的发送序号", func(s *TestServer) {
+			s.SendIFrame(frame)
+			s.SendDuplicateSSN()
+		}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			c := newTestClient(t, s, Config{})
+			tt.inject(s)
+			s.SendIFrame(frame)
+			deadline := time.Now().Add(2 * time.Second)
+			for c.IFrameNum() != tt.want {
+				if time.Now().After(deadline) {
+					t.Fatal("违反规约的帧之后未继续处理数据")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if h := c.Health(); h.Reconnects != 0 {
+				t.Errorf("Client.Health() = %+v, want no reconnect", h)
+			}
+		})
+	}
+}
+
+func TestClient_PauseResume(t *testing.T) {
+	s := newTestServer(t)
+	ioas := make(chan uint32, 3)
+	c := newTestClientWithTask(t, s, Config{AckWindow: 1, PauseBuffer: 2}, func(apdu *APDU) {
+		ioas <- apdu.Signals[0].Address
+	})
+	c.Pause()
+	for ioa := byte(1); ioa <= 3; ioa++ {
+		s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, ioa, 0x00, 0x00, 0x01})
+	}
+	//暂停期间仍确认收到的I帧
+	timeout := time.After(2 * time.Second)
+	for acked := false; !acked; {
+		select {
+		case frame := <-s.frames:
+			acked = frame[2]&3 == sFrame && binary.LittleEndian.Uint16(frame[4:6])>>1 == 3
+		case <-timeout:
+			t.Fatal("暂停期间未确认收到的I帧")
+		}
+	}
+	select {
+	case ioa := <-ioas:
+		t.Fatalf("暂停期间task收到信息对象%d", ioa)
+	case <-time.After(50 * time.Millisecond):
+	}
+	c.Resume()
+	got := make(map[uint32]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case ioa := <-ioas:
+			got[ioa] = true
+		case <-time.After(2 * time.Second):
+			t.Fatal("Resume后未交付缓存的数据")
+		}
+	}
+	if !got[2] || !got[3] {
+		t.Errorf("Resume后交付的信息对象 = %v, want 2和3", got)
+	}
+}
+
+func TestClient_TestMode(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{TestMode: true})
+	go c.SendSingleCommand(1, 1, true, false)
+	frame := s.nextIFrame(t, CScNa1)
+	if frame[8] != 0x86 {
+		t.Errorf("试验模式命令的传输原因 = %#x, want 0x86", frame[8])
+	}
+}
+
+func TestClient_SweepPending(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{Clock: clock})
+	//从站只回激活确认,不发送激活终止
+	const n = 100
+	for i := 1; i <= n; i++ {
+		errc := make(chan error, 1)
+		go func(ioa uint32) {
+			errc <- c.SendSingleCommand(1, ioa, true, false)
+		}(uint32(i))
+		asdu := append([]byte{}, s.nextIFrame(t, CScNa1)[6:]...)
+		asdu[2] = 7
+		s.SendIFrame(asdu)
+		if err := <-errc; err != nil {
+			t.Fatalf("Client.SendSingleCommand() error = %v", err)
+		}
+	}
+	if got := len(c.PendingCommands()); got != n {
+		t.Fatalf("Client.PendingCommands() = %d, want %d", got, n)
+	}
+	clock.Advance(terminationTimeout)
+	go c.SendSingleCommand(1, n+1, true, false)
+	s.nextIFrame(t, CScNa1)
+	if got := c.PendingCommands(); len(got) != 1 || got[0].IOA != n+1 {
+		t.Errorf("Client.PendingCommands() = %v, want only ioa %d", got, n+1)
+	}
+}
+
+func TestClient_SendFloatSetpoint(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	if _, err := c.SendFloatSetpoint(1, 0x6201, 1.5, 0x80, false); err == nil {
+		t.Error("Client.SendFloatSetpoint() QL超过7位时未返回错误")
+	}
+	type result struct {
+		applied float32
+		err     error
+	}
+	res := make(chan result, 1)
+	go func() {
+		applied, err := c.SendFloatSetpoint(1, 0x6201, 1.5, 5, true)
+		res <- result{applied, err}
+	}()
+	//选择和执行的QOS均保留QL,仅S/E位不同
+	for _, wantQOS := range []byte{0x85, 0x05} {
+		frame := s.nextIFrame(t, CSeNc1)
+		if qos := frame[len(frame)-1]; qos != wantQOS {
+			t.Fatalf("设定命令限定词 = %#x, want %#x", qos, wantQOS)
+		}
+		asdu := append([]byte{}, frame[6:]...)
+		asdu[2] = 7
+		s.SendIFrame(asdu)
+	}
+	select {
+	case r := <-res:
+		if r.err != nil || r.applied != 1.5 {
+			t.Errorf("Client.SendFloatSetpoint() = %v, %v, want 1.5, nil", r.applied, r.err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("收到激活确认后SendFloatSetpoint未返回")
+	}
+}
+
+func TestClient_LastSeen(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{Clock: clock})
+	if _, ok := c.LastSeen(2); ok {
+		t.Fatal("Client.LastSeen() 未收到数据时ok = true")
+	}
+	s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x02, 0x00, 0x01, 0x00, 0x00, 0x01})
+	deadline := time.Now().Add(2 * time.Second)
+	for c.IFrameNum() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("等待处理数据超时")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, ok := c.LastSeen(2); !ok || !got.Equal(clock.Now()) {
+		t.Errorf("Client.LastSeen() = %v, %v, want %v, true", got, ok, clock.Now())
+	}
+	if _, ok := c.LastSeen(1); ok {
+		t.Error("Client.LastSeen() 其他公共地址ok = true")
+	}
+}
+
+func TestClient_StartDtRetry(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestServer(t)
+	s.dropStartDt = 1
+	go func() {
+		//总召唤定时器、t3定时器和t1定时器
+		if clock.waitTimers(3, time.Second) {
+			clock.Advance(confirmTimeout)
+		}
+	}()
+	newTestClient(t, s, Config{Clock: clock})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.startDts != 2 {
+		t.Errorf("收到%d个启动激活帧, want 2", s.startDts)
+	}
+}
+
+func TestClient_OnData(t *testing.T) {
+	s := newTestServer(t)
+	ioas := make(chan uint32, 2)
+	newTestClient(t, s, Config{OnData: func(apdu *APDU) { ioas <- apdu.Signals[0].Address }})
+	for ioa := byte(1); ioa <= 2; ioa++ {
+		s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, ioa, 0x00, 0x00, 0x01})
+	}
+	//按接收顺序调用
+	for want := uint32(1); want <= 2; want++ {
+		select {
+		case ioa := <-ioas:
+			if ioa != want {
+				t.Errorf("OnData() ioa = %d, want %d", ioa, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("收到数据后未调用OnData")
+		}
+	}
+}
+
+func TestClient_InterrogationAddrs(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{
+		InterrogationAddrs:       []uint16{1, 2},
+		InterrogationSpacing:     10 * time.Millisecond,
+		SkipCounterInterrogation: true,
+	})
+	frame := s.nextIFrame(t, CIcNa1)
+	if commonAddr := binary.LittleEndian.Uint16(frame[10:12]); commonAddr != 2 {
+		t.Fatalf("第二个总召唤的公共地址 = %d, want 2", commonAddr)
+	}
+	s.SendIFrame([]byte{0x64, 0x01, 0x0a, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x14})
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		statuses := c.InterrogationStatus()
+		if len(statuses) == 2 && statuses[0].InProgress && !statuses[1].InProgress && !statuses[1].LastComplete.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Client.InterrogationStatus() = %+v, want 1 in progress, 2 complete", statuses)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClient_StopDtByStation(t *testing.T) {
+	s := newTestServer(t)
+	changes := make(chan bool, 2)
+	c := newTestClient(t, s, Config{OnActiveChange: func(active bool) { changes <- active }})
+	if active := <-changes; !active {
+		t.Fatal("启动确认后OnActiveChange(false), want true")
+	}
+	s.mu.Lock()
+	s.conn.Write(convertBytes(stopDtAct[:]))
+	s.mu.Unlock()
+	timeout := time.After(2 * time.Second)
+	for confirmed := false; !confirmed; {
+		select {
+		case frame := <-s.frames:
+			confirmed = bytes.Equal(frame[2:6], stopDtCon[:])
+		case <-timeout:
+			t.Fatal("未收到停止确认帧")
+		}
+	}
+	select {
+	case active := <-changes:
+		if active {
+			t.Error("停止激活后OnActiveChange(true), want false")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("停止激活后未调用OnActiveChange")
+	}
+	if err := c.SendSingleCommand(1, 1, true, false); err != ErrNotActive {
+		t.Errorf("Client.SendSingleCommand() error = %v, want %v", err, ErrNotActive)
+	}
+}
+
+func TestClient_LogValues(t *testing.T) {
+	clock := newFakeClock()
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &buf
+	logger.Level = logrus.InfoLevel
+	c := NewClient("127.0.0.1:0", logger)
+	c.Config = Config{Clock: clock, LogValues: true, LogValuesPerSecond: 1}
+	apdu := &APDU{
+		ASDU:    &ASDU{PublicAddress: 1},
+		Signals: []*Signal{{TypeID: 1, Address: 1, Value: 1, Cause: 3}, {TypeID: 1, Address: 2, Cause: 3}},
+	}
+	c.logValues(apdu)
+	if n := bytes.Count(buf.Bytes(), []byte("信息对象地址")); n != 1 {
+		t.Fatalf("打印了%d条数据日志, want 1", n)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("类型:M_SP_NA_1")) {
+		t.Errorf("数据日志 = %q, want 类型名称", buf.String())
+	}
+	clock.Advance(time.Second)
+	c.logValues(apdu)
+	if !bytes.Contains(buf.Bytes(), []byte("限流未打印1条数据日志")) {
+		t.Errorf("数据日志 = %q, want 汇总限流条数", buf.String())
+	}
+}
+
+func TestClient_SeqState(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	if ssn, rsn, acked, outstanding := c.SeqState(); ssn != 1 || rsn != 0 || acked != 0 || outstanding != 1 {
+		t.Fatalf("Client.SeqState() = %d, %d, %d, %d, want 1, 0, 0, 1", ssn, rsn, acked, outstanding)
+	}
+	//对端I帧确认了总召唤命令
+	s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		ssn, rsn, acked, outstanding := c.SeqState()
+		if ssn == 1 && rsn == 1 && acked == 1 && outstanding == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Client.SeqState() = %d, %d, %d, %d, want 1, 1, 1, 0", ssn, rsn, acked, outstanding)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClient_SendBitstring32WithTime(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	ts := time.Date(2021, 3, 4, 5, 6, 7, 0, time.Local)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.SendBitstring32WithTime(1, 0x6401, 0x80000001, ts)
+	}()
+	frame := s.nextIFrame(t, CBoTa1)
+	//最高位为比特串的一部分,不是选择位
+	if got := binary.LittleEndian.Uint32(frame[15:19]); got != 0x80000001 {
+		t.Fatalf("发送的比特串 = %#x, want 0x80000001", got)
+	}
+	if got, _ := ParseCP56Time2a(frame[19:26]); !got.Equal(ts) {
+		t.Fatalf("发送的时标 = %v, want %v", got, ts)
+	}
+	asdu := append([]byte{}, frame[6:]...)
+	asdu[2] = 7
+	s.SendIFrame(asdu)
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("Client.SendBitstring32WithTime() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("收到激活确认后SendBitstring32WithTime未返回")
+	}
+}
+
+func TestClient_InterrogateQueued(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	type result struct {
+		signals []*Signal
+		err     error
+	}
+	interrogate := func(qoi byte) chan result {
+		resc := make(chan result, 1)
+		go func() {
+			signals, err := c.Interrogate(context.Background(), 1, qoi)
+			resc <- result{signals, err}
+		}()
+		return resc
+	}
+	//回复一个信息对象和召唤结束
+	reply := func(frame []byte, ioa byte) {
+		s.SendIFrame([]byte{0x01, 0x01, frame[15], 0x00, 0x01, 0x00, ioa, 0x00, 0x00, 0x01})
+		asdu := append([]byte{}, frame[6:]...)
+		asdu[2] = 10
+		s.SendIFrame(asdu)
+	}
+	endStationCall(t, s, c, 1)
+	group1 := interrogate(QoiGroup1)
+	frame1 := s.nextIFrame(t, CIcNa1)
+	group2 := interrogate(QoiGroup1 + 1)
+	select {
+	case frame := <-s.frames:
+		if frame[2]&1 == iFrame && frame[6] == CIcNa1 {
+			t.Fatal("第1组召唤结束前发送了第2组召唤")
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+	reply(frame1, 1)
+	frame2 := s.nextIFrame(t, CIcNa1)
+	if frame2[15] != QoiGroup1+1 {
+		t.Fatalf("第二个召唤的限定词 = %d, want %d", frame2[15], QoiGroup1+1)
+	}
+	reply(frame2, 2)
+	for i, resc := range []chan result{group1, group2} {
+		select {
+		case res := <-resc:
+			if res.err != nil || len(res.signals) != 1 || res.signals[0].Address != uint32(i+1) {
+				t.Errorf("第%d组Client.Interrogate() = %v, %v, want ioa %d", i+1, res.signals, res.err, i+1)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("第%d组召唤结束后Interrogate未返回", i+1)
+		}
+	}
+	c.Config.RejectConcurrentInterrogation = true
+	interrogate(QoiGroup1)
+	s.nextIFrame(t, CIcNa1)
+	if _, err := c.Interrogate(context.Background(), 1, QoiStation); err != ErrInterrogationInProgress {
+		t.Errorf("Client.Interrogate() error = %v, want %v", err, ErrInterrogationInProgress)
+	}
+}
+
+func TestClient_Drain(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{PauseBuffer: 2})
+	c.Pause()
+	for ioa := byte(1); ioa <= 2; ioa++ {
+		s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, ioa, 0x00, 0x00, 0x01})
+	}
+	var frames []*APDU
+	deadline := time.Now().Add(2 * time.Second)
+	for len(frames) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Client.Drain() 取出%d帧, want 2", len(frames))
+		}
+		time.Sleep(10 * time.Millisecond)
+		frames = append(frames, c.Drain()...)
+	}
+	c.Close()
+	for i, apdu := range frames {
+		if apdu.Signals[0].Address != uint32(i+1) {
+			t.Errorf("Client.Drain()[%d] ioa = %d, want %d", i, apdu.Signals[0].Address, i+1)
+		}
+	}
+	if frames := c.Drain(); frames == nil || len(frames) != 0 {
+		t.Errorf("Close后Client.Drain() = %v, want empty slice", frames)
+	}
+}
+
+func TestClient_RunContext(t *testing.T) {
+	s := newTestServer(t)
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	c := NewClient(s.Addr(), logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.RunContext(ctx, func(*APDU) {})
+	}()
+	s.nextIFrame(t, CIcNa1)
+	cancel()
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			t.Errorf("Client.RunContext() error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ctx取消后RunContext未返回")
+	}
+	if c.connected() {
+		t.Error("RunContext返回后仍保持连接")
+	}
+}
+
+func TestClient_RunContextDone(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		prepare func(c *Client)
+		wantErr error
+	}{
+		{"测试ctx已取消", cancelled, func(*Client) {}, context.Canceled},
+		{"测试客户端已关闭", context.Background(), func(c *Client) { c.Close() }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			logger := logrus.New()
+			logger.Out = ioutil.Discard
+			c := NewClient(s.Addr(), logger)
+			tt.prepare(c)
+			errc := make(chan error, 1)
+			go func() {
+				errc <- c.RunContext(tt.ctx, func(*APDU) {})
+			}()
+			select {
+			case err := <-errc:
+				if err != tt.wantErr {
+					t.Errorf("Client.RunContext() error = %v, want %v", err, tt.wantErr)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("RunContext未立即返回")
+			}
+			s.mu.Lock()
+			conn := s.conn
+			s.mu.Unlock()
+			if conn != nil {
+				t.Error("RunContext返回前建立了连接")
+			}
+			if c.ctx.Err() == nil {
+				t.Error("RunContext返回后客户端未关闭")
+			}
+		})
+	}
+}
+
+func TestClient_OnSequenceError(t *testing.T) {
+	frame := []byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01}
+	tests := []struct {
+		name   string
+		inject func(s *TestServer)
+		want   SeqErrorDetail
+	}{
+		{"测试重复的发送序号", func(s *TestServer) {
+			s.SendIFrame(frame)
+			s.SendDuplicateSSN()
+			s.SendIFrame(frame)
+		}, SeqErrorDetail{Kind: SeqErrorSend, Expected: 1, Received: 0}},
+		{"测试确认未发送的I帧", func(s *TestServer) {
+			s.mu.Lock()
+			s.conn.Write(convertBytes([]byte{0x01, 0x00, 0x0a, 0x00}))
+			s.mu.Unlock()
+		}, SeqErrorDetail{Kind: SeqErrorAck, Expected: 1, Received: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			details := make(chan SeqErrorDetail, 1)
+			newTestClient(t, s, Config{OnSequenceError: func(detail SeqErrorDetail) { details <- detail }})
+			tt.inject(s)
+			select {
+			case got := <-details:
+				if got.Kind != tt.want.Kind || got.Expected != tt.want.Expected || got.Received != tt.want.Received || len(got.Frame) == 0 {
+					t.Errorf("OnSequenceError() detail = %+v, want %+v", got, tt.want)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("序号异常时未调用OnSequenceError")
+			}
+		})
+	}
+}
+
+func TestClient_SendReadCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     []byte //从站的响应
+		wantValue float64
+		wantErr   bool
+	}{
+		{"测试请求上送的遥测", []byte{0x0b, 0x01, 0x05, 0x00, 0x01, 0x00, 0x01, 0x40, 0x00, 0x64, 0x00, 0x00}, 100, false},
+		{"测试未知的信息对象地址", []byte{0x66, 0x01, 0x2f, 0x00, 0x01, 0x00, 0x01, 0x40, 0x00}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			c := newTestClient(t, s, Config{})
+			type result struct {
+				signal *Signal
+				err    error
+			}
+			resc := make(chan result, 1)
+			go func() {
+				signal, err := c.SendReadCommand(context.Background(), 1, 0x4001)
+				resc <- result{signal, err}
+			}()
+			frame := s.nextIFrame(t, CRdNa1)
+			if frame[8] != 5 || len(frame) != 15 {
+				t.Fatalf("读命令 = [% X], want 传输原因5且没有信息元素", frame)
+			}
+			//突发上送的同一信息对象不作为读命令的响应
+			s.SendIFrame([]byte{0x0b, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x40, 0x00, 0x01, 0x00, 0x00})
+			s.SendIFrame(tt.reply)
+			select {
+			case res := <-resc:
+				if (res.err != nil) != tt.wantErr {
+					t.Fatalf("Client.SendReadCommand() error = %v, wantErr %v", res.err, tt.wantErr)
+				}
+				if !tt.wantErr && (res.signal.Value != tt.wantValue || res.signal.Cause != 5) {
+					t.Errorf("Client.SendReadCommand() = %+v, want value %v", res.signal, tt.wantValue)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("收到响应后SendReadCommand未返回")
+			}
+		})
+	}
+}
+
+func TestClient_SendReadCommandCancel(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.SendReadCommand(ctx, 1, 0x4001)
+		errc <- err
+	}()
+	s.nextIFrame(t, CRdNa1)
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("Client.SendReadCommand() error = %v, want %v", err, context.Canceled)
+	}
+	//撤销后的读重新发送读命令
+	type result struct {
+		signal *Signal
+		err    error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		signal, err := c.SendReadCommand(context.Background(), 1, 0x4001)
+		resc <- result{signal, err}
+	}()
+	s.nextIFrame(t, CRdNa1)
+	//肯定的读命令镜像不是否定响应
+	s.SendIFrame([]byte{0x66, 0x01, 0x07, 0x00, 0x01, 0x00, 0x01, 0x40, 0x00})
+	s.SendIFrame([]byte{0x0b, 0x01, 0x05, 0x00, 0x01, 0x00, 0x01, 0x40, 0x00, 0x64, 0x00, 0x00})
+	select {
+	case res := <-resc:
+		if res.err != nil || res.signal.Value != 100 {
+			t.Errorf("Client.SendReadCommand() = %+v, %v, want value 100", res.signal, res.err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("收到响应后SendReadCommand未返回")
+	}
+}
+
+func TestClient_DefaultQualifiers(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{DefaultQOI: QoiGroup1, DefaultQCC: RqtGroup1, DefaultQRP: QrpTimeTagged})
+	if qoi, qcc, qrp := c.DefaultQualifiers(); qoi != QoiGroup1 || qcc != RqtGroup1 || qrp != QrpTimeTagged {
+		t.Fatalf("Client.DefaultQualifiers() = %d, %d, %d, want %d, %d, %d", qoi, qcc, qrp, QoiGroup1, RqtGroup1, QrpTimeTagged)
+	}
+	//初始化结束后重新总召唤,召唤结束后发送电度总召唤
+	s.SendIFrame([]byte{0x46, 0x01, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00})
+	if frame := s.nextIFrame(t, CIcNa1); frame[15] != QoiGroup1 {
+		t.Errorf("总召唤限定词 = %d, want %d", frame[15], QoiGroup1)
+	}
+	s.SendIFrame([]byte{0x64, 0x01, 0x0a, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, QoiGroup1})
+	if frame := s.nextIFrame(t, CCiNa1); frame[15] != RqtGroup1 {
+		t.Errorf("电度总召唤限定词 = %d, want %d", frame[15], RqtGroup1)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.SendResetProcess(1)
+	}()
+	frame := s.nextIFrame(t, CRpNa1)
+	if frame[15] != QrpTimeTagged {
+		t.Errorf("复位进程命令限定词 = %d, want %d", frame[15], QrpTimeTagged)
+	}
+	asdu := append([]byte{}, frame[6:]...)
+	asdu[2] = 7
+	s.SendIFrame(asdu)
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("Client.SendResetProcess() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("收到激活确认后SendResetProcess未返回")
+	}
+	if len(c.PendingCommands()) != 0 {
+		t.Error("复位进程命令激活确认后仍处于未完成状态")
+	}
+}
+
+func TestClient_StationCallOverlap(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{SkipCounterInterrogation: true})
+	type result struct {
+		signals []*Signal
+		err     error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		signals, err := c.Interrogate(context.Background(), 1, QoiGroup1)
+		resc <- result{signals, err}
+	}()
+	//自动总召唤结束前不发送用户召唤
+	select {
+	case frame := <-s.frames:
+		if frame[2]&1 == iFrame && frame[6] == CIcNa1 {
+			t.Fatal("自动总召唤结束前发送了召唤")
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+	endStationCall(t, s, c, 1)
+	frame := s.nextIFrame(t, CIcNa1)
+	if frame[15] != QoiGroup1 {
+		t.Fatalf("召唤限定词 = %d, want %d", frame[15], QoiGroup1)
+	}
+	//用户召唤进行中不发送自动总召唤,自动召唤的召唤结束不会提前结束用户召唤
+	c.sendTotalCall()
+	select {
+	case frame := <-s.frames:
+		if frame[2]&1 == iFrame && frame[6] == CIcNa1 {
+			t.Fatal("用户召唤进行中发送了自动总召唤")
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+	s.SendIFrame([]byte{0x01, 0x01, 0x15, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+	asdu := append([]byte{}, frame[6:]...)
+	asdu[2] = 10
+	s.SendIFrame(asdu)
+	select {
+	case res := <-resc:
+		if res.err != nil || len(res.signals) != 1 {
+			t.Errorf("Client.Interrogate() = %v, %v, want 1 signal", res.signals, res.err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("召唤结束后Interrogate未返回")
+	}
+}
+
+func TestClient_InterrogateWithTestFrame(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	endStationCall(t, s, c, 1)
+	type result struct {
+		signals []*Signal
+		err     error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		signals, err := c.Interrogate(context.Background(), 1, QoiStation)
+		resc <- result{signals, err}
+	}()
+	frame := s.nextIFrame(t, CIcNa1)
+	asdu := append([]byte{}, frame[6:]...)
+	asdu[2] = 7
+	s.SendIFrame(asdu)
+	s.SendIFrame([]byte{0x01, 0x01, 0x14, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+	//召唤过程中对端发送测试激活
+	s.mu.Lock()
+	s.conn.Write(convertBytes(testFrAct[:]))
+	s.mu.Unlock()
+	timeout := time.After(2 * time.Second)
+	for confirmed := false; !confirmed; {
+		select {
+		case frame := <-s.frames:
+			confirmed = bytes.Equal(frame[2:6], testFrCon[:])
+		case <-timeout:
+			t.Fatal("召唤过程中未回复测试确认帧")
+		}
+	}
+	s.SendIFrame([]byte{0x01, 0x01, 0x14, 0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00})
+	asdu[2] = 10
+	s.SendIFrame(asdu)
+	select {
+	case res := <-resc:
+		if res.err != nil || len(res.signals) != 2 || res.signals[0].Address != 1 || res.signals[1].Address != 2 {
+			t.Errorf("Client.Interrogate() = %v, %v, want ioa 1和2", res.signals, res.err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("召唤结束后Interrogate未返回")
+	}
+}
+
+func TestClient_TypeIDFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"测试只处理浮点遥测", Config{IncludeTypeIDs: []byte{MMeNc1}}},
+		{"测试丢弃单点遥信", Config{ExcludeTypeIDs: []byte{MSpNa1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			types := make(chan byte, 2)
+			c := newTestClientWithTask(t, s, tt.config, func(apdu *APDU) { types <- apdu.ASDU.TypeID })
+			s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+			s.SendIFrame([]byte{0x0d, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x40, 0x00, 0x00, 0x00, 0x80, 0x3f, 0x00})
+			select {
+			case typeID := <-types:
+				if typeID != MMeNc1 {
+					t.Errorf("task收到类型%d, want %d", typeID, MMeNc1)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("未交付浮点遥测")
+			}
+			if h := c.Health(); h.FilteredFrames != 1 {
+				t.Errorf("Client.Health().FilteredFrames = %d, want 1", h.FilteredFrames)
+			}
+		})
+	}
+}
+
+func TestClient_StopStartDT(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := c.StopDT(ctx); err != nil {
+		t.Fatalf("Client.StopDT() error = %v", err)
+	}
+	if err := c.SendSingleCommand(1, 1, true, false); err != ErrNotActive {
+		t.Errorf("停止后Client.SendSingleCommand() error = %v, want %v", err, ErrNotActive)
+	}
+	if err := c.StartDT(ctx); err != nil {
+		t.Fatalf("Client.StartDT() error = %v", err)
+	}
+	//启动确认后的总召唤从序号0开始
+	frame := s.nextIFrame(t, CIcNa1)
+	if ssn, rsn := binary.LittleEndian.Uint16(frame[2:4])>>1, binary.LittleEndian.Uint16(frame[4:6])>>1; ssn != 0 || rsn != 0 {
+		t.Errorf("重新启动后总召唤的序号 = %d, %d, want 0, 0", ssn, rsn)
+	}
+	if _, _, _, outstanding := c.SeqState(); outstanding != 1 {
+		t.Errorf("重新启动后未确认的I帧 = %d, want 1", outstanding)
+	}
+}
+
+func TestClient_ReconnectPolicy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	//关闭监听,连接被拒绝
+	ln.Close()
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	c := NewClient(ln.Addr().String(), logger)
+	c.Config.ReconnectPolicy = ExponentialBackoff{Initial: time.Millisecond, MaxAttempts: 2}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.RunContext(context.Background(), func(*APDU) {})
+	}()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Error("停止重连后Client.RunContext() error = nil, want 连接异常")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("重连策略停止重连后RunContext未返回")
+	}
+	if err := c.SendSingleCommand(1, 1, true, false); err != ErrNotConnected {
+		t.Errorf("Client.SendSingleCommand() error = %v, want %v", err, ErrNotConnected)
+	}
+}
+
+func TestClient_CallDirectory(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	if err := c.CallDirectory(1); err != nil {
+		t.Fatalf("Client.CallDirectory() error = %v", err)
+	}
+	frame := s.nextIFrame(t, FScNa1)
+	if cause := frame[8]; cause != 13 {
+		t.Errorf("召唤目录的传输原因 = %d, want 13", cause)
+	}
+	if !bytes.Equal(frame[12:], []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}) {
+		t.Errorf("召唤目录的信息对象 = [% X], want 全0", frame[12:])
+	}
+}
+
+func TestClient_StrictAckWindow(t *testing.T) {
+	s := newTestServer(t)
+	received := make(chan struct{}, 3)
+	c := newTestClientWithTask(t, s, Config{AckWindow: 3, StrictAckWindow: true}, func(*APDU) { received <- struct{}{} })
+	single := []byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01}
+	for i := 0; i < 2; i++ {
+		s.SendIFrame(single)
+		select {
+		case <-received:
+		case <-time.After(2 * time.Second):
+			t.Fatal("等待task处理I帧超时")
+		}
+	}
+	//未达到w时发送的I帧不捎带确认
+	if err := c.CallDirectory(1); err != nil {
+		t.Fatalf("Client.CallDirectory() error = %v", err)
+	}
+	select {
+	case frame := <-s.frames:
+		if frame[6] != FScNa1 || binary.LittleEndian.Uint16(frame[4:6])>>1 != 0 {
+			t.Errorf("第2个I帧后发送[% X], want 接收序号为0的召唤目录命令", frame)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待召唤目录命令超时")
+	}
+	s.SendIFrame(single)
+	select {
+	case frame := <-s.frames:
+		if frame[2] != sFrame || binary.LittleEndian.Uint16(frame[4:6])>>1 != 3 {
+			t.Errorf("第3个I帧后发送[% X], want 接收序号为3的S帧", frame)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("收到第w个I帧后未发送S帧")
+	}
+}
+
+func TestClient_SendCommandWithReturn(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	//确认命令后上送返送信息(传输原因11)和激活终止
+	reply := func(frame []byte, info []byte) {
+		asdu := append([]byte{}, frame[6:]...)
+		asdu[2] = 7
+		s.SendIFrame(asdu)
+		s.SendIFrame(info)
+		asdu[2] = 10
+		s.SendIFrame(asdu)
+	}
+	t.Run("测试双点命令", func(t *testing.T) {
+		type result struct {
+			signal *Signal
+			err    error
+		}
+		resc := make(chan result, 1)
+		go func() {
+			signal, err := c.SendDoubleCommandWithReturn(1, 0x6001, true, false, 0x0101)
+			resc <- result{signal, err}
+		}()
+		reply(s.nextIFrame(t, CDcNa1), []byte{0x03, 0x01, 0x0b, 0x00, 0x01, 0x00, 0x01, 0x01, 0x00, 0x02})
+		select {
+		case r := <-resc:
+			if r.err != nil || r.signal.Value != 2 || r.signal.Address != 0x0101 {
+				t.Errorf("Client.SendDoubleCommandWithReturn() = %+v, %v, want 合位", r.signal, r.err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("收到返送信息后SendDoubleCommandWithReturn未返回")
+		}
+	})
+	t.Run("测试调节步命令", func(t *testing.T) {
+		type result struct {
+			p   StepPosition
+			err error
+		}
+		resc := make(chan result, 1)
+		go func() {
+			p, err := c.SendRegulatingStepCommandWithReturn(1, 0x6002, true, false, 0x0102)
+			resc <- result{p, err}
+		}()
+		frame := s.nextIFrame(t, CRcNa1)
+		if rco := frame[15]; rco != 0x02 {
+			t.Errorf("调节步命令限定词 = %#x, want 0x02", rco)
+		}
+		reply(frame, []byte{0x05, 0x01, 0x0b, 0x00, 0x01, 0x00, 0x02, 0x01, 0x00, 0x06, 0x00})
+		select {
+		case r := <-resc:
+			if r.err != nil || r.p.Value != 6 {
+				t.Errorf("Client.SendRegulatingStepCommandWithReturn() = %+v, %v, want 6档", r.p, r.err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("收到返送信息后SendRegulatingStepCommandWithReturn未返回")
+		}
+	})
+	t.Run("测试连续的调节步命令", func(t *testing.T) {
+		//第2个命令在第1个命令收到返送信息后才发送,每个命令得到各自执行后的档位
+		resc := make(chan int, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				p, err := c.SendRegulatingStepCommandWithReturn(1, 0x6002, true, false, 0x0102)
+				if err != nil {
+					t.Errorf("Client.SendRegulatingStepCommandWithReturn() error = %v", err)
+				}
+				resc <- int(p.Value)
+			}()
+		}
+		got := map[int]bool{}
+		for _, pos := range []byte{0x07, 0x08} {
+			reply(s.nextIFrame(t, CRcNa1), []byte{0x05, 0x01, 0x0b, 0x00, 0x01, 0x00, 0x02, 0x01, 0x00, pos, 0x00})
+			select {
+			case v := <-resc:
+				got[v] = true
+			case <-time.After(2 * time.Second):
+				t.Fatal("收到返送信息后SendRegulatingStepCommandWithReturn未返回")
+			}
+		}
+		if !got[7] || !got[8] {
+			t.Errorf("连续调节后的档位 = %v, want 7档和8档", got)
+		}
+	})
+}
+
+func TestClient_Shutdown(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := c.Shutdown(ctx); err != nil {
+		t.Fatalf("Client.Shutdown() error = %v", err)
+	}
+	s.mu.Lock()
+	stopDts := s.stopDts
+	s.mu.Unlock()
+	if stopDts != 1 {
+		t.Errorf("收到的停止激活帧数量 = %d, want 1", stopDts)
+	}
+	select {
+	case <-c.ctx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Shutdown后客户端未关闭")
+	}
+}
+
+func TestClient_TestInterval(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestServer(t)
+	received := make(chan struct{}, 1)
+	c := newTestClientWithTask(t, s, Config{Clock: clock, TestInterval: 30 * time.Second, TotalCallInterval: -1}, func(*APDU) { received <- struct{}{} })
+	//确认总召唤,前进时间时不因t1断开
+	s.SendSFrame()
+	waitUnacknowledged(t, c, 0)
+	//testFrame timeout内收到测试激活帧时返回true
+	testFrame := func(timeout time.Duration) bool {
+		deadline := time.After(timeout)
+		for {
+			select {
+			case frame := <-s.frames:
+				if bytes.Equal(frame[2:6], testFrAct[:]) {
+					return true
+				}
+			case <-deadline:
+				return false
+			}
+		}
+	}
+	if !clock.waitTimers(1, time.Second) {
+		t.Fatal("等待t3定时器超时")
+	}
+	clock.Advance(20 * time.Second)
+	s.SendIFrame([]byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01})
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待task处理I帧超时")
+	}
+	//收到报文后t3重新计时,距上次收到报文15秒时不发送测试激活帧
+	clock.Advance(15 * time.Second)
+	if testFrame(100 * time.Millisecond) {
+		t.Fatal("t3到期前发送了测试激活帧")
+	}
+	if !clock.waitTimers(1, time.Second) {
+		t.Fatal("等待t3定时器超时")
+	}
+	clock.Advance(15 * time.Second)
+	if !testFrame(2 * time.Second) {
+		t.Fatal("t3到期后未发送测试激活帧")
+	}
+}
+
+func TestClient_InterrogateAll(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{CommonAddrs: []uint16{1, 2}})
+	//公共地址1的自动召唤结束前,其响应交给自动召唤
+	endStationCall(t, s, c, 1)
+	type result struct {
+		byAddr map[uint16][]*Signal
+		err    error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		byAddr, err := c.InterrogateAll(context.Background(), QoiStation)
+		resc <- result{byAddr, err}
+	}()
+	var frame []byte
+	for frame == nil || binary.LittleEndian.Uint16(frame[10:12]) != BroadcastCommonAddr {
+		frame = s.nextIFrame(t, CIcNa1)
+	}
+	//各公共地址分别确认、上送数据和结束召唤
+	reply := func(commonAddr byte, cause byte) {
+		asdu := append([]byte{}, frame[6:]...)
+		asdu[2] = cause
+		asdu[4], asdu[5] = commonAddr, 0
+		s.SendIFrame(asdu)
+	}
+	reply(1, 7)
+	reply(2, 7)
+	s.SendIFrame([]byte{0x01, 0x02, 0x14, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x02, 0x00, 0x00, 0x00})
+	s.SendIFrame([]byte{0x01, 0x01, 0x14, 0x00, 0x02, 0x00, 0x01, 0x00, 0x00, 0x01})
+	reply(1, 10)
+	select {
+	case r := <-resc:
+		t.Fatalf("第2个公共地址结束前InterrogateAll返回 %v, %v", r.byAddr, r.err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	reply(2, 10)
+	select {
+	case r := <-resc:
+		if r.err != nil {
+			t.Fatalf("Client.InterrogateAll() error = %v", r.err)
+		}
+		if len(r.byAddr) != 2 || len(r.byAddr[1]) != 2 || len(r.byAddr[2]) != 1 {
+			t.Errorf("Client.InterrogateAll() = %v, want 公共地址1两个、公共地址2一个信息对象", r.byAddr)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("所有公共地址召唤结束后InterrogateAll未返回")
+	}
+}
+
+func TestClient_InterrogateBroadcast(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s, Config{})
+	type result struct {
+		signals []*Signal
+		err     error
+	}
+	resc := make(chan result, 1)
+	go func() {
+		signals, err := c.Interrogate(context.Background(), BroadcastCommonAddr, QoiStation)
+		resc <- result{signals, err}
+	}()
+	var frame []byte
+	for frame == nil || binary.LittleEndian.Uint16(frame[10:12]) != BroadcastCommonAddr {
+		frame = s.nextIFrame(t, CIcNa1)
+	}
+	asdu := append([]byte{}, frame[6:]...)
+	asdu[2] = 7
+	s.SendIFrame(asdu)
+	//各公共地址的数据合并后按公共地址排序
+	s.SendIFrame([]byte{0x01, 0x01, 0x14, 0x00, 0x02, 0x00, 0x03, 0x00, 0x00, 0x01})
+	s.SendIFrame([]byte{0x01, 0x02, 0x14, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x02, 0x00, 0x00, 0x00})
+	asdu = append([]byte{}, frame[6:]...)
+	asdu[2] = 10
+	s.SendIFrame(asdu)
+	select {
+	case r := <-resc:
+		if r.err != nil {
+			t.Fatalf("Client.Interrogate() error = %v", r.err)
+		}
+		if len(r.signals) != 3 || r.signals[0].Address != 1 || r.signals[1].Address != 2 || r.signals[2].Address != 3 {
+			t.Errorf("Client.Interrogate() = %v, want 公共地址1的信息对象1、2和公共地址2的信息对象3", r.signals)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("全局公共地址召唤结束后Interrogate未返回")
+	}
+}
+
+//memSeqStore 保存在内存中的序号状态
+type memSeqStore struct {
+	mu  sync.Mutex
+	seq SeqNumbers
+	ok  bool
+}
+
+func (m *memSeqStore) Load() (SeqNumbers, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.seq, m.ok, nil
+}
+
+func (m *memSeqStore) Save(seq SeqNumbers) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq, m.ok = seq, true
+	return nil
+}
+
+func TestClient_SeqStore(t *testing.T) {
+	s := newTestServer(t)
+	store := &memSeqStore{seq: SeqNumbers{SendSeq: 10, PeerAck: 10}, ok: true}
+	c := newTestClient(t, s, Config{SeqStore: store})
+	//连接后的总召唤沿用保存的发送序号10
+	if seq, _, _ := store.Load(); seq.SendSeq != 11 {
+		t.Errorf("总召唤后保存的发送序号 = %d, want 11", seq.SendSeq)
+	}
+	if err := c.CallDirectory(1); err != nil {
+		t.Fatalf("Client.CallDirectory() error = %v", err)
+	}
+	frame := s.nextIFrame(t, FScNa1)
+	if ssn := binary.LittleEndian.Uint16(frame[2:4]) >> 1; ssn != 11 {
+		t.Errorf("召唤目录命令的发送序号 = %d, want 11", ssn)
+	}
+	//StartDT重新开始数据传输时序号清零,之后的总召唤发送序号为0
+	c.setActive(false)
+	if err := c.StartDT(context.Background()); err != nil {
+		t.Fatalf("Client.StartDT() error = %v", err)
+	}
+	frame = s.nextIFrame(t, CIcNa1)
+	if ssn := binary.LittleEndian.Uint16(frame[2:4]) >> 1; ssn != 0 {
+		t.Errorf("StartDT后总召唤的发送序号 = %d, want 0", ssn)
+	}
+}
+
+func TestClient_SendPriority(t *testing.T) {
+	s := newTestServer(t)
+	//k为1,连接后的总召唤未被确认,之后的I帧排队等待
+	c := newTestClient(t, s, Config{SendWindow: 1})
+	waitQueued := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			c.mu.Lock()
+			queued := len(c.sendWaiters)
+			c.mu.Unlock()
+			if queued == n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("等待%d个I帧排队超时,当前%d个", n, queued)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Interrogate(ctx, 2, QoiStation)
+	waitQueued(1)
+	go c.SendSingleCommand(1, 1, true, false)
+	waitQueued(2)
+	nextIFrame := func() byte {
+		t.Helper()
+		for {
+			select {
+			case frame := <-s.frames:
+				if frame[2]&1 == iFrame {
+					return frame[6]
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("等待I帧超时")
+			}
+		}
+	}
+	single := []byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01}
+	for _, want := range []byte{CScNa1, CIcNa1} {
+		//对端的I帧确认已发送的I帧,发送窗口空出后优先级高的先发送
+		s.SendIFrame(single)
+		if got := nextIFrame(); got != want {
+			t.Errorf("确认后发送的I帧类型 = %d, want %d", got, want)
+		}
+	}
+}
+
+func TestClient_RawCopied(t *testing.T) {
+	s := newTestServer(t)
+	received := make(chan *APDU, 2)
+	newTestClientWithTask(t, s, Config{}, func(apdu *APDU) { received <- apdu })
+	//读缓冲区每帧复用,先收到的帧的Raw不能被后一帧覆盖
+	first := []byte{0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01}
+	second := []byte{0x01, 0x01, 0x03, 0x00, 0x02, 0x00, 0x02, 0x00, 0x00, 0x00}
+	s.SendIFrame(first)
+	s.SendIFrame(second)
+	var apdus []*APDU
+	for len(apdus) < 2 {
+		select {
+		case apdu := <-received:
+			apdus = append(apdus, apdu)
+		case <-time.After(2 * time.Second):
+			t.Fatal("等待task处理I帧超时")
+		}
+	}
+	//task按接收顺序依次调用
+	if !bytes.Equal(apdus[0].Raw[6:], first) || !bytes.Equal(apdus[1].Raw[6:], second) {
+		t.Errorf("Raw = [% X], [% X], want ASDU [% X], [% X]", apdus[0].Raw, apdus[1].Raw, first, second)
+	}
+}
+
+func TestClient_OnStaleData(t *testing.T) {
+	s := newTestServer(t)
+	events := make(chan string, 4)
+	newTestClient(t, s, Config{
+		OnStaleData:   func(commonAddr uint16, ioa uint32) { events <- fmt.Sprintf("stale %d %#x", commonAddr, ioa) },
+		OnTopicalData: func(commonAddr uint16, ioa uint32) { events <- fmt.Sprintf("topical %d %#x", commonAddr, ioa) },
+	})
+	measured := func(qds byte) []byte {
+		return []byte{MMeNa1, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x40, 0x00, 0x34, 0x12, qds}
+	}
+	//连续两次非当前值只通知一次,恢复为当前值时通知一次
+	for _, qds := range []byte{0x40, 0x40, 0x00, 0x00} {
+		s.SendIFrame(measured(qds))
+	}
+	for _, want := range []string{"stale 1 0x4001", "topical 1 0x4001"} {
+		select {
+		case got := <-events:
+			if got != want {
+				t.Errorf("回调 = %s, want %s", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("等待回调%s超时", want)
+		}
+	}
+	select {
+	case got := <-events:
+		t.Errorf("多余的回调 %s", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestLibraryMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		libraryMode bool
+		wantClosed  bool
+	}{
+		{"测试库模式不响应退出信号", true, false},
+		{"测试默认收到退出信号关闭客户端", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//测试自身监听退出信号,避免库模式下信号终止测试进程
+			signals := make(chan os.Signal, 2)
+			signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+			defer signal.Stop(signals)
+			LibraryMode = tt.libraryMode
+			defer func() { LibraryMode = false }()
+			s := newTestServer(t)
+			c := newTestClient(t, s, Config{})
+			p, err := os.FindProcess(os.Getpid())
+			if err != nil {
+				t.Fatalf("os.FindProcess() error = %v", err)
+			}
+			deadline := time.Now().Add(time.Second)
+			for _, sig := range []os.Signal{syscall.SIGINT, syscall.SIGTERM} {
+				//客户端的信号监听协程可能尚未启动,重复发送直至客户端关闭或超时
+				for !c.isClosing() && time.Now().Before(deadline) {
+					if err := p.Signal(sig); err != nil {
+						t.Fatalf("Process.Signal(%v) error = %v", sig, err)
+					}
+					<-signals
+					time.Sleep(50 * time.Millisecond)
+					if tt.libraryMode {
+						break
+					}
+				}
+			}
+			if got := c.isClosing(); got != tt.wantClosed {
+				t.Errorf("Client.isClosing() = %v, want %v", got, tt.wantClosed)
+			}
+			if got := c.Health().Connected; got == tt.wantClosed {
+				t.Errorf("Client.Health().Connected = %v, want %v", got, !tt.wantClosed)
+			}
+		})
+	}
+}
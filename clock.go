@@ -0,0 +1,45 @@
+package iec104
+
+import "time"
+
+//Clock 时钟,客户端的定时器和超时均通过Clock获取,测试时可替换为模拟时钟。
+//socket读写超时由操作系统计时,始终使用真实时间
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	After(d time.Duration) <-chan time.Time
+}
+
+//Ticker 周期定时器
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+//realClock 系统时钟
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+//realTicker 系统定时器
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTicker) Stop() {
+	t.t.Stop()
+}
@@ -0,0 +1,118 @@
+package iec104
+
+import (
+	"sync"
+	"time"
+)
+
+//fakeClock 模拟时钟,仅在Advance时前进
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	changed chan struct{}
+}
+
+//fakeTimer 模拟定时器,period为0时只触发一次
+type fakeTimer struct {
+	clock   *fakeClock
+	at      time.Time
+	period  time.Duration
+	ch      chan time.Time
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:     time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local),
+		changed: make(chan struct{}, 1),
+	}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) NewTicker(d time.Duration) Ticker {
+	return f.addTimer(d, d)
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	return f.addTimer(d, 0).ch
+}
+
+func (f *fakeClock) addTimer(d, period time.Duration) *fakeTimer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTimer{clock: f, at: f.now.Add(d), period: period, ch: make(chan time.Time, 1)}
+	f.timers = append(f.timers, t)
+	select {
+	case f.changed <- struct{}{}:
+	default:
+	}
+	return t
+}
+
+//Advance 前进d并触发到期的定时器
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	timers := f.timers[:0]
+	for _, t := range f.timers {
+		if t.stopped {
+			continue
+		}
+		for !t.at.After(f.now) {
+			select {
+			case t.ch <- f.now:
+			default:
+			}
+			if t.period == 0 {
+				t.stopped = true
+				break
+			}
+			t.at = t.at.Add(t.period)
+		}
+		if !t.stopped {
+			timers = append(timers, t)
+		}
+	}
+	f.timers = timers
+}
+
+//waitTimers 等待至少n个定时器处于等待状态
+func (f *fakeClock) waitTimers(n int, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for {
+		f.mu.Lock()
+		count := 0
+		for _, t := range f.timers {
+			if !t.stopped {
+				count++
+			}
+		}
+		f.mu.Unlock()
+		if count >= n {
+			return true
+		}
+		select {
+		case <-f.changed:
+		case <-time.After(time.Millisecond):
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+}
@@ -0,0 +1,581 @@
+package iec104
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+//命令限定词QU,位于单点、双点、调节步命令限定词的bit2~bit6
+const (
+	QuNone       byte = 0 << 2 //无附加定义
+	QuShortPulse byte = 1 << 2 //短脉冲输出
+	QuLongPulse  byte = 2 << 2 //长脉冲输出
+	QuPersistent byte = 3 << 2 //持续输出
+)
+
+//selectBit 命令限定词最高位,1为选择,0为执行
+const selectBit byte = 0x80
+
+//maxQL 设定命令限定词QOS中QL的最大值,QOS最高位为S/E
+const maxQL byte = 0x7f
+
+//testBit 传输原因最高位T,1为试验
+const testBit byte = 0x80
+
+var (
+	//ErrNotConnected 未连接服务器
+	ErrNotConnected = errors.New("未连接服务器")
+	//ErrCommandTimeout 等待命令确认超时
+	ErrCommandTimeout = errors.New("等待命令确认超时")
+	//ErrCommandRejected 命令被否定确认
+	ErrCommandRejected = errors.New("命令被否定确认")
+	//ErrCommandCancelled 命令已被撤销
+	ErrCommandCancelled = errors.New("命令已被撤销")
+	//ErrNoPendingCommand 信息对象没有可撤销的命令
+	ErrNoPendingCommand = errors.New("信息对象没有可撤销的命令")
+	//ErrNotActive 未收到启动确认(STARTDT_CON),数据传输未启动
+	ErrNotActive = errors.New("数据传输未启动")
+	//ErrStartDtTimeout 重发启动激活后仍未收到启动确认
+	ErrStartDtTimeout = errors.New("未收到启动确认")
+	//ErrSetpointMismatch 激活确认中回送的设定值与发送的不一致,从站可能限幅或未采用该值
+	ErrSetpointMismatch = errors.New("确认的设定值与发送的不一致")
+	//ErrReturnTimeout 命令执行确认后等待返送信息超时
+	ErrReturnTimeout = errors.New("等待返送信息超时")
+	//ErrAckTimeout 已发送的I帧超过t1未被对端确认,连接断开重连
+	ErrAckTimeout = errors.New("等待对端确认I帧超时")
+)
+
+//Command 控制命令
+type Command struct {
+	TypeID     byte      //类型标识
+	CommonAddr uint16    //公共地址
+	IOA        uint32    //信息对象地址
+	Value      []byte    //信息元素,单点、双点、调节步命令为1个字节的命令限定词,时钟同步命令为空
+	Select     bool      //是否选择后执行
+	Time       time.Time //带时标命令(类型58~64)和时钟同步命令的时标,零值表示不带时标
+	//Timeout 等待确认的超时时间,为0时使用Config.CommandTimeouts中该类型的超时时间,未配置时为10秒
+	Timeout time.Duration
+}
+
+//CommandState 命令执行状态
+type CommandState int
+
+const (
+	//CommandSelecting 已发送选择,等待确认
+	CommandSelecting CommandState = iota
+	//CommandExecuting 已发送执行,等待确认
+	CommandExecuting
+	//CommandAwaitingTermination 已确认,等待激活终止
+	CommandAwaitingTermination
+)
+
+//String 命令状态名称
+func (s CommandState) String() string {
+	switch s {
+	case CommandSelecting:
+		return "selecting"
+	case CommandExecuting:
+		return "executing"
+	case CommandAwaitingTermination:
+		return "awaiting-termination"
+	}
+	return fmt.Sprintf("CommandState(%d)", int(s))
+}
+
+//PendingCommand 未完成命令的描述
+type PendingCommand struct {
+	TypeID     byte         //类型标识
+	CommonAddr uint16       //公共地址
+	IOA        uint32       //信息对象地址
+	State      CommandState //执行状态
+	SentAt     time.Time    //最近一次发送选择或执行的时间
+}
+
+//pointKey 公共地址+信息对象地址
+type pointKey struct {
+	commonAddr uint16
+	ioa        uint32
+}
+
+//pendingCommand 等待确认的命令
+type pendingCommand struct {
+	cmd          Command
+	state        CommandState
+	value        []byte //最近一次发送的信息元素
+	sentAt       time.Time
+	deactivating bool
+	echo         float64    //激活确认中回送的值
+	lock         *pointLock //信息对象的命令锁,命令从pending中移除时释放
+	result       chan error //选择、执行的确认结果
+	deact        chan error //撤销的确认结果
+}
+
+//pointLock 信息对象的命令锁,由命令持有直至从pending中移除,refs为0时从map中删除
+type pointLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+//notify 写入确认结果,无人等待时丢弃
+func notify(ch chan error, err error) {
+	select {
+	case ch <- err:
+	default:
+	}
+}
+
+//SendSingleCommand 发送单点命令(C_SC_NA_1),sbo为true时先选择后执行
+func (c *Client) SendSingleCommand(commonAddr uint16, ioa uint32, on bool, sbo bool) error {
+	return c.SendCommand(singleCommand(commonAddr, ioa, on, sbo))
+}
+
+//singleCommand 单点命令,无附加定义
+func singleCommand(commonAddr uint16, ioa uint32, on bool, sbo bool) Command {
+	var sco byte
+	if on {
+		sco = 0x01
+	}
+	return Command{TypeID: CScNa1, CommonAddr: commonAddr, IOA: ioa, Value: []byte{sco}, Select: sbo}
+}
+
+//SendSingleCommandWithTime 发送带时标CP56Time2a的单点命令(C_SC_TA_1)
+func (c *Client) SendSingleCommandWithTime(commonAddr uint16, ioa uint32, on bool, sbo bool, t time.Time) error {
+	cmd := singleCommand(commonAddr, ioa, on, sbo)
+	cmd.TypeID = CScTa1
+	cmd.Time = t
+	return c.SendCommand(cmd)
+}
+
+//SendBitstring32WithTime 发送带时标CP56Time2a的32比特串命令(C_BO_TA_1),比特串命令没有选择
+func (c *Client) SendBitstring32WithTime(commonAddr uint16, ioa uint32, value uint32, t time.Time) error {
+	return c.SendCommand(bitstring32Command(commonAddr, ioa, value, t))
+}
+
+//bitstring32Command 带时标CP56Time2a的32比特串命令
+func bitstring32Command(commonAddr uint16, ioa uint32, value uint32, t time.Time) Command {
+	bsi := make([]byte, 4)
+	binary.LittleEndian.PutUint32(bsi, value)
+	return Command{TypeID: CBoTa1, CommonAddr: commonAddr, IOA: ioa, Value: bsi, Time: t}
+}
+
+//hasQualifier 命令的信息元素最后一个字节是否为带选择位的命令限定词,比特串命令没有限定词,复位进程命令限定词没有选择位
+func hasQualifier(typeID byte) bool {
+	return typeID != CBoTa1 && typeID != CRpNa1
+}
+
+//SendClockSync 向commonAddr发送时钟同步命令(C_CS_NA_1),等待激活确认。
+//时钟同步、复位进程、召唤和计数量召唤等针对整个站的命令,信息对象地址为0
+func (c *Client) SendClockSync(commonAddr uint16, t time.Time) error {
+	return c.SendCommand(Command{TypeID: CCsNa1, CommonAddr: commonAddr, IOA: 0, Time: t})
+}
+
+//复位进程命令限定词QRP
+const (
+	QrpGeneral    byte = 1 //进程的总复位
+	QrpTimeTagged byte = 2 //复位事件缓冲区内等待处理的带时标信息
+)
+
+//SendResetProcess 向commonAddr发送复位进程命令(C_RP_NA_1),限定词为Config.DefaultQRP,等待激活确认
+func (c *Client) SendResetProcess(commonAddr uint16) error {
+	return c.SendCommand(Command{TypeID: CRpNa1, CommonAddr: commonAddr, IOA: 0, Value: []byte{c.defaultQRP()}})
+}
+
+//hasTermination 命令在激活确认之后是否还有激活终止,时钟同步和复位进程只有激活确认
+func hasTermination(typeID byte) bool {
+	return typeID != CCsNa1 && typeID != CRpNa1
+}
+
+//SendScaledSetpoint 发送标度化值设定命令(C_SE_NB_1),ql为设定命令限定词QOS的QL(0~127),无特殊定义时为0。
+//比较激活确认中回送的设定值,返回从站确认的设定值,与value不一致时返回ErrSetpointMismatch
+func (c *Client) SendScaledSetpoint(commonAddr uint16, ioa uint32, value int16, ql byte, sbo bool) (int16, error) {
+	if ql > maxQL {
+		return 0, fmt.Errorf("设定命令限定词QL[%d]超过%d", ql, maxQL)
+	}
+	p, err := c.sendCommand(scaledSetpointCommand(commonAddr, ioa, value, ql, sbo))
+	if p == nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int16(p.echo), err
+}
+
+//SendFloatSetpoint 发送短浮点数设定命令(C_SE_NC_1),ql为设定命令限定词QOS的QL(0~127),无特殊定义时为0。
+//比较激活确认中回送的设定值,返回从站确认的设定值,与value不一致时返回ErrSetpointMismatch
+func (c *Client) SendFloatSetpoint(commonAddr uint16, ioa uint32, value float32, ql byte, sbo bool) (float32, error) {
+	if ql > maxQL {
+		return 0, fmt.Errorf("设定命令限定词QL[%d]超过%d", ql, maxQL)
+	}
+	p, err := c.sendCommand(floatSetpointCommand(commonAddr, ioa, value, ql, sbo))
+	if p == nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return float32(p.echo), err
+}
+
+//scaledSetpointCommand 标度化值设定命令,2个字节的设定值加1个字节的QOS
+func scaledSetpointCommand(commonAddr uint16, ioa uint32, value int16, ql byte, sbo bool) Command {
+	nva := parseLittleEndianUInt16(uint16(value))
+	return Command{TypeID: CSeNb1, CommonAddr: commonAddr, IOA: ioa, Value: []byte{nva[0], nva[1], ql}, Select: sbo}
+}
+
+//floatSetpointCommand 短浮点数设定命令,4个字节的设定值加1个字节的QOS
+func floatSetpointCommand(commonAddr uint16, ioa uint32, value float32, ql byte, sbo bool) Command {
+	v := make([]byte, 5)
+	binary.LittleEndian.PutUint32(v, math.Float32bits(value))
+	v[4] = ql
+	return Command{TypeID: CSeNc1, CommonAddr: commonAddr, IOA: ioa, Value: v, Select: sbo}
+}
+
+//checkEcho 校验设定值命令激活确认中回送的值,调用方需持有mu
+func (p *pendingCommand) checkEcho(s *Signal) error {
+	var match bool
+	switch p.cmd.TypeID {
+	case CSeNb1:
+		match = len(p.value) >= 2 && int16(binary.LittleEndian.Uint16(p.value[:2])) == int16(s.Value)
+	case CSeNc1:
+		match = len(p.value) >= 4 && math.Float32frombits(binary.LittleEndian.Uint32(p.value[:4])) == float32(s.Value)
+	default:
+		return nil
+	}
+	p.echo = s.Value
+	if !match {
+		return ErrSetpointMismatch
+	}
+	return nil
+}
+
+//SendDoubleCommand 发送双点命令(C_DC_NA_1),on为true时为合(DCS=2),否则为分(DCS=1)
+func (c *Client) SendDoubleCommand(commonAddr uint16, ioa uint32, on bool, sbo bool) error {
+	return c.SendCommand(doubleCommand(commonAddr, ioa, on, sbo))
+}
+
+//doubleCommand 双点命令,无附加定义
+func doubleCommand(commonAddr uint16, ioa uint32, on bool, sbo bool) Command {
+	dco := byte(0x01)
+	if on {
+		dco = 0x02
+	}
+	return Command{TypeID: CDcNa1, CommonAddr: commonAddr, IOA: ioa, Value: []byte{dco}, Select: sbo}
+}
+
+//SendCommand 发送控制命令并等待激活确认,Select为true时先选择后执行。
+//收到肯定确认后返回,命令保持待终止状态直至收到激活终止或被撤销。
+//可并发调用:同一公共地址和信息对象地址的命令依次执行,选择和执行之间不会插入其他命令,
+//前一命令等待激活终止期间后续命令继续等待,直至收到激活终止、撤销或等待激活终止超时,
+//等待时间计入超时时间;不同信息对象的命令互不阻塞
+func (c *Client) SendCommand(cmd Command) error {
+	_, err := c.sendCommand(cmd)
+	return err
+}
+
+//sendCommand 发送控制命令,返回已发送的命令,未发送时为nil
+func (c *Client) sendCommand(cmd Command) (*pendingCommand, error) {
+	if len(cmd.Value) == 0 && cmd.Time.IsZero() {
+		return nil, fmt.Errorf("命令[%d]信息元素为空", cmd.TypeID)
+	}
+	if cmd.Select && !hasQualifier(cmd.TypeID) {
+		return nil, fmt.Errorf("命令[%d]不支持选择", cmd.TypeID)
+	}
+	if !c.connected() {
+		return nil, ErrNotConnected
+	}
+	if err := c.waitCommandToken(c.timeoutFor(cmd)); err != nil {
+		return nil, err
+	}
+	key := pointKey{cmd.CommonAddr, cmd.IOA}
+	l, err := c.lockPoint(key, c.timeoutFor(cmd))
+	if err != nil {
+		return nil, err
+	}
+	p := &pendingCommand{
+		cmd:    cmd,
+		lock:   l,
+		result: make(chan error, 1),
+		deact:  make(chan error, 1),
+	}
+	c.mu.Lock()
+	c.pending[key] = p
+	c.mu.Unlock()
+	if cmd.Select {
+		if err := c.commandStep(key, p, CommandSelecting); err != nil {
+			return p, err
+		}
+	}
+	return p, c.commandStep(key, p, CommandExecuting)
+}
+
+//timeoutFor 命令等待确认的超时时间
+func (c *Client) timeoutFor(cmd Command) time.Duration {
+	if cmd.Timeout > 0 {
+		return cmd.Timeout
+	}
+	if d, ok := c.Config.CommandTimeouts[cmd.TypeID]; ok && d > 0 {
+		return d
+	}
+	return commandTimeout
+}
+
+//lockPoint 获取信息对象的命令锁,最多等待timeout。先清除等待激活终止超时的命令,释放其持有的锁
+func (c *Client) lockPoint(key pointKey, timeout time.Duration) (*pointLock, error) {
+	c.mu.Lock()
+	c.sweepPending()
+	l, ok := c.pointLocks[key]
+	if !ok {
+		l = &pointLock{ch: make(chan struct{}, 1)}
+		c.pointLocks[key] = l
+	}
+	l.refs++
+	c.mu.Unlock()
+	err := ErrCommandTimeout
+	select {
+	case l.ch <- struct{}{}:
+		return l, nil
+	case <-c.clock().After(timeout):
+	case <-c.ctx.Done():
+		err = ErrNotConnected
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.releasePointLock(key, l)
+	return nil, err
+}
+
+//dropPending 从pending中移除命令并释放其持有的命令锁,可重复调用。调用方需持有mu
+func (c *Client) dropPending(key pointKey, p *pendingCommand) {
+	if c.pending[key] == p {
+		delete(c.pending, key)
+	}
+	if p.lock != nil {
+		<-p.lock.ch
+		c.releasePointLock(key, p.lock)
+		p.lock = nil
+	}
+}
+
+//releasePointLock 减少命令锁引用,无引用时删除。调用方需持有mu
+func (c *Client) releasePointLock(key pointKey, l *pointLock) {
+	l.refs--
+	if l.refs == 0 {
+		delete(c.pointLocks, key)
+	}
+}
+
+//encodeCommandValue 编码选择或执行的信息元素,带时标的命令在限定词后加7个字节时标
+func encodeCommandValue(cmd Command, state CommandState) []byte {
+	value := append([]byte(nil), cmd.Value...)
+	//时钟同步等只有时标的命令和比特串命令没有命令限定词
+	if len(value) > 0 && hasQualifier(cmd.TypeID) {
+		if state == CommandSelecting {
+			value[len(value)-1] |= selectBit
+		} else {
+			value[len(value)-1] &^= selectBit
+		}
+	}
+	if !cmd.Time.IsZero() {
+		value = append(value, encodeCP56Time2a(cmd.Time)...)
+	}
+	return value
+}
+
+//commandStep 发送选择或执行,并等待确认
+func (c *Client) commandStep(key pointKey, p *pendingCommand, state CommandState) error {
+	value := encodeCommandValue(p.cmd, state)
+	c.mu.Lock()
+	p.state = state
+	p.value = value
+	p.sentAt = c.clock().Now()
+	c.mu.Unlock()
+	data, err := c.sendIFrameTimeout(encodeCommandASDU(p.cmd.TypeID, c.cause(CauseActivation), c.Config.Originator, p.cmd.CommonAddr, p.cmd.IOA, value), c.timeoutFor(p.cmd))
+	if err != nil {
+		c.removePending(key, p)
+		return err
+	}
+	c.Logger.Debugf("发送命令,类型:%s,公共地址:%d,信息对象地址:%d: [% X]", TypeIDName(p.cmd.TypeID), p.cmd.CommonAddr, p.cmd.IOA, data)
+	select {
+	case err := <-p.result:
+		return err
+	case <-c.clock().After(c.timeoutFor(p.cmd)):
+		c.removePending(key, p)
+		return ErrCommandTimeout
+	}
+}
+
+//DeactivateCommand 撤销信息对象上未完成的命令(如持续输出),等待撤销确认
+func (c *Client) DeactivateCommand(commonAddr uint16, ioa uint32) error {
+	key := pointKey{commonAddr, ioa}
+	c.mu.Lock()
+	p, ok := c.pending[key]
+	if !ok || p.value == nil {
+		c.mu.Unlock()
+		return ErrNoPendingCommand
+	}
+	c.mu.Unlock()
+	if err := c.waitCommandToken(c.timeoutFor(p.cmd)); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	p.deactivating = true
+	value := p.value
+	c.mu.Unlock()
+	data, err := c.sendIFrameTimeout(encodeCommandASDU(p.cmd.TypeID, c.cause(CauseDeactivation), c.Config.Originator, commonAddr, ioa, value), c.timeoutFor(p.cmd))
+	if err != nil {
+		c.mu.Lock()
+		p.deactivating = false
+		c.mu.Unlock()
+		return err
+	}
+	c.Logger.Debugf("发送撤销命令,类型:%s,公共地址:%d,信息对象地址:%d: [% X]", TypeIDName(p.cmd.TypeID), commonAddr, ioa, data)
+	select {
+	case err := <-p.deact:
+		return err
+	case <-c.clock().After(c.timeoutFor(p.cmd)):
+		c.mu.Lock()
+		p.deactivating = false
+		c.mu.Unlock()
+		return ErrCommandTimeout
+	}
+}
+
+//handleCommandResponse 处理控制方向的确认、撤销确认和激活终止,批量命令的响应可包含多个信息对象
+func (c *Client) handleCommandResponse(apdu *APDU) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range apdu.Signals {
+		c.handleCommandSignal(apdu.ASDU, s)
+	}
+}
+
+//handleCommandSignal 处理一个信息对象的命令响应,调用方需持有mu
+func (c *Client) handleCommandSignal(asdu *ASDU, s *Signal) {
+	key := pointKey{asdu.PublicAddress, s.Address}
+	p, ok := c.pending[key]
+	if !ok || p.cmd.TypeID != asdu.TypeID {
+		c.Logger.Warnf("收到未知命令的响应,类型:%s,公共地址:%d,信息对象地址:%d,原因:%d", TypeIDName(asdu.TypeID), key.commonAddr, key.ioa, asdu.Cause)
+		return
+	}
+	switch asdu.Cause {
+	case CauseActivationCon:
+		if asdu.Negative {
+			c.dropPending(key, p)
+			notify(p.result, ErrCommandRejected)
+			return
+		}
+		err := p.checkEcho(s)
+		if p.state == CommandExecuting {
+			if !hasTermination(p.cmd.TypeID) {
+				c.dropPending(key, p)
+			}
+			p.state = CommandAwaitingTermination
+		} else if err != nil {
+			//选择确认的值不一致时不再执行
+			c.dropPending(key, p)
+		}
+		notify(p.result, err)
+	case CauseDeactivationCon:
+		if !p.deactivating {
+			return
+		}
+		p.deactivating = false
+		if asdu.Negative {
+			notify(p.deact, ErrCommandRejected)
+			return
+		}
+		c.dropPending(key, p)
+		notify(p.result, ErrCommandCancelled)
+		notify(p.deact, nil)
+	case CauseActivationTermination:
+		c.dropPending(key, p)
+		notify(p.result, nil)
+	case CauseUnknownTypeID, CauseUnknownCause, CauseUnknownCommonAddr, CauseUnknownIOA:
+		c.dropPending(key, p)
+		err := fmt.Errorf("命令被拒绝,传输原因:%d", asdu.Cause)
+		notify(p.result, err)
+		notify(p.deact, err)
+	}
+}
+
+//PendingCommands 返回所有未完成的命令(等待选择确认、执行确认或激活终止),按公共地址和信息对象地址排序
+func (c *Client) PendingCommands() []PendingCommand {
+	c.mu.Lock()
+	commands := make([]PendingCommand, 0, len(c.pending))
+	for key, p := range c.pending {
+		if p.value == nil {
+			continue
+		}
+		commands = append(commands, PendingCommand{
+			TypeID:     p.cmd.TypeID,
+			CommonAddr: key.commonAddr,
+			IOA:        key.ioa,
+			State:      p.state,
+			SentAt:     p.sentAt,
+		})
+	}
+	c.mu.Unlock()
+	sort.Slice(commands, func(i, j int) bool {
+		if commands[i].CommonAddr != commands[j].CommonAddr {
+			return commands[i].CommonAddr < commands[j].CommonAddr
+		}
+		return commands[i].IOA < commands[j].IOA
+	})
+	return commands
+}
+
+//sweepPending 清除等待激活终止超时的命令,避免对端不发送激活终止时pending无限增长。调用方需持有mu
+func (c *Client) sweepPending() {
+	timeout := c.Config.TerminationTimeout
+	if timeout <= 0 {
+		timeout = terminationTimeout
+	}
+	now := c.clock().Now()
+	for key, p := range c.pending {
+		if p.state == CommandAwaitingTermination && now.Sub(p.sentAt) >= timeout {
+			c.Logger.Warnf("等待激活终止超时,类型:%s,公共地址:%d,信息对象地址:%d", TypeIDName(p.cmd.TypeID), key.commonAddr, key.ioa)
+			c.dropPending(key, p)
+			notify(p.result, ErrCommandTimeout)
+			notify(p.deact, ErrCommandTimeout)
+		}
+	}
+}
+
+//removePending 移除等待确认的命令
+func (c *Client) removePending(key pointKey, p *pendingCommand) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dropPending(key, p)
+}
+
+//failPendingCommands 连接断开时结束所有等待确认的命令
+func (c *Client) failPendingCommands(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, p := range c.pending {
+		notify(p.result, err)
+		notify(p.deact, err)
+		c.dropPending(key, p)
+	}
+}
+
+//cause 发送的传输原因,Config.TestMode为true时置T位
+func (c *Client) cause(cause Cause) byte {
+	if c.Config.TestMode {
+		return byte(cause) | testBit
+	}
+	return byte(cause)
+}
+
+//encodeCommandASDU 编码单个信息对象的控制方向ASDU
+func encodeCommandASDU(typeID byte, cause byte, originator byte, commonAddr uint16, ioa uint32, value []byte) []byte {
+	data := make([]byte, 0, 9+len(value))
+	data = append(data, typeID, 0x01, cause, originator)
+	data = append(data, encodeCommonAddr(commonAddr)...)
+	data = append(data, encodeIOA(ioa)...)
+	data = append(data, value...)
+	return data
+}
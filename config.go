@@ -0,0 +1,245 @@
+package iec104
+
+import (
+	"net"
+	"time"
+)
+
+//defaultClock 未配置Clock时使用系统时钟
+var defaultClock Clock = realClock{}
+
+//LibraryMode 嵌入其他程序时设为true,不监听SIGINT和SIGTERM等退出信号,由调用方决定何时关闭客户端,只影响信号处理。
+//库在任何模式下都不退出进程或panic,不支持的类型标识等解析异常通过error返回。需在创建客户端之前设置
+var LibraryMode bool
+
+//LenientParse 为true时ASDU中某个信息对象不完整或无法解析,保留之前已解析的信息对象并记录到ASDU.ObjectError,
+//不再丢弃整帧。需在创建客户端之前设置
+var LenientParse bool
+
+//BigEndianAddr 为true时公共地址和信息对象地址按大端序编码和解析,仅用于对接不符合规约的网关。
+//规约规定为小端序,设置错误时地址全部错乱,命令可能发往错误的信息对象。需在创建客户端之前设置
+var BigEndianAddr bool
+
+//PointAddr 信息对象的公共地址和信息对象地址
+type PointAddr struct {
+	CommonAddr uint16
+	IOA        uint32
+}
+
+//Config 客户端可选配置,需在Run之前设置
+type Config struct {
+	//LocalAddr 本地绑定的ip和端口,为nil时由系统分配,断线重连时使用同一地址。
+	//固定端口时重连可能因TIME_WAIT绑定失败,会按重连间隔继续重试
+	LocalAddr *net.TCPAddr
+	//ReconnectPolicy 连接服务器失败后的重连策略,为nil时每隔5秒重试,不停止。可使用ExponentialBackoff
+	ReconnectPolicy ReconnectPolicy
+	//FlushTimeout Close时等待发送队列清空的最长时间,为0时直接断开
+	FlushTimeout time.Duration
+	//LogIFrames 为true时每收到一个数据I帧打印一条debug日志
+	LogIFrames bool
+	//LogValues 为true时以info级别打印收到的每个信息对象的类型、地址、值、品质和传输原因
+	LogValues bool
+	//LogValuesPerSecond LogValues每秒最多打印的条数,超出的丢弃并汇总条数,为0时不限制
+	LogValuesPerSecond int
+	//Clock 时钟,为nil时使用系统时钟
+	Clock Clock
+	//CommonAddr 自动发送的总召唤使用的公共地址,为0时使用1。电度总召唤发往总召唤结束的公共地址
+	CommonAddr uint16
+	//Originator 发送的ASDU中的源发地址
+	Originator byte
+	//InterrogationAddrs 连接建立、初始化结束、周期总召唤和数据超时时自动总召唤的公共地址,为空时只召唤CommonAddr。
+	//各公共地址的召唤状态见InterrogationStatus
+	InterrogationAddrs []uint16
+	//InterrogationSpacing 自动召唤多个公共地址时相邻两个公共地址的间隔,避免同时上送大量数据,为0时使用1秒
+	InterrogationSpacing time.Duration
+	//CommonAddrs 预期的公共地址,为空时不校验
+	CommonAddrs []uint16
+	//RejectUnexpectedCommonAddr 为true时丢弃公共地址不在CommonAddrs中的帧(仍会确认),否则只告警
+	RejectUnexpectedCommonAddr bool
+	//OnUnexpectedCommonAddr 收到公共地址不在CommonAddrs中的帧时调用
+	OnUnexpectedCommonAddr func(commonAddr uint16, apdu *APDU)
+	//AckTimeout t2,收到I帧后没有I帧可捎带确认时,最长等待该时间发送S帧,为0时使用10秒
+	AckTimeout time.Duration
+	//AckWindow w,未确认的I帧达到该数量时立即发送S帧,为0时使用8
+	AckWindow int
+	//StrictAckWindow 为true时严格按w确认:只在收到第w个I帧或t2到期时发送S帧,
+	//发送I帧时只捎带已确认的接收序号,停止数据传输时也不提前确认
+	StrictAckWindow bool
+	//SeqStore 序号状态的持久化,不为nil时进程启动后的第一次连接恢复保存的收发序号,之后序号变化时保存。
+	//为nil时不保存,每次连接序号从0开始。只有对端也保留会话状态时才需要配置,见SeqStore
+	SeqStore SeqStore
+	//SendWindow k,已发送未被确认的I帧达到该数量时暂停发送I帧,收到对端确认后继续,为0时使用12
+	SendWindow int
+	//SendPriority I帧的发送调度策略,返回类型标识的优先级,多个I帧等待发送时优先级高的先发送,相同优先级按调用顺序。
+	//为nil时使用DefaultSendPriority。S帧和U帧不参与调度,总是优先发送
+	SendPriority func(typeID byte) int
+	//CommandTimeouts 按类型标识配置命令等待确认的超时时间,未配置的类型为10秒,Command.Timeout优先
+	CommandTimeouts map[byte]time.Duration
+	//OnActiveChange 数据传输状态变化时调用:收到启动确认时为true,收到对端的停止激活(STOPDT_ACT)时为false。
+	//停止后发送命令和召唤返回ErrNotActive,直至重新连接
+	OnActiveChange func(active bool)
+	//RejectConcurrentInterrogation 为true时同一公共地址已有召唤正在进行时返回ErrInterrogationInProgress,否则排队等待
+	RejectConcurrentInterrogation bool
+	//InterrogationTimeout 发送召唤命令后等待召唤结束的最长时间,超时后Interrogate返回已收到的信息对象和
+	//ErrInterrogationIncomplete,用于负载高时丢弃召唤结束帧的从站。为0时一直等待,只受ctx控制
+	InterrogationTimeout time.Duration
+	//DefaultQOI 自动总召唤和Interrogate未指定限定词时使用的召唤限定词QOI,为0时使用QoiStation
+	DefaultQOI byte
+	//DefaultQCC 自动电度总召唤使用的计数量召唤命令限定词QCC,为0时使用RqtGeneral|FrzRead
+	DefaultQCC byte
+	//DefaultQRP SendResetProcess使用的复位进程命令限定词QRP,为0时使用QrpGeneral
+	DefaultQRP byte
+	//OnInterrogationComplete 收到总召唤结束(类型100,传输原因10)时调用,此时该公共地址的全部数据已上送
+	OnInterrogationComplete func(commonAddr uint16)
+	//SkipCounterInterrogation 为true时总召唤结束后不自动发送电度总召唤
+	SkipCounterInterrogation bool
+	//WriteTimeout 单次写socket的超时时间,超时后断开重连,为0时使用10秒
+	WriteTimeout time.Duration
+	//StaleDataTimeout 连接正常但超过该时间未收到任何数据时重新发送总召唤,为0时不检查。
+	//按该间隔检查,最长在2倍时间后发送
+	StaleDataTimeout time.Duration
+	//OnProtectionEvent 收到继电保护设备事件(类型38~40)时按信息对象调用,之后仍交给task处理
+	OnProtectionEvent func(commonAddr uint16, s *Signal, event *ProtectionEvent)
+	//AllowedPoints 信息对象白名单,为空时不校验。监视方向过程信息(类型1~44)中有信息对象不在白名单中时丢弃整帧(仍会确认),
+	//丢弃的帧数见HealthStatus.RejectedFrames
+	AllowedPoints []PointAddr
+	//OnRejectedPoint 收到白名单之外的信息对象时调用
+	OnRejectedPoint func(commonAddr uint16, s *Signal)
+	//IncludeTypeIDs 只处理这些类型标识的监视方向过程信息(类型1~44),为空时不过滤。
+	//过滤掉的帧仍会确认,但不交给task和回调,数量见HealthStatus.FilteredFrames
+	IncludeTypeIDs []byte
+	//ExcludeTypeIDs 丢弃这些类型标识的监视方向过程信息,在IncludeTypeIDs之后过滤
+	ExcludeTypeIDs []byte
+	//OnData 收到监视方向数据帧时调用,可代替Run的task使用。在读协程中按接收顺序依次调用,
+	//调用时该帧已确认,调用返回前不会读取下一帧,耗时的处理应交给其他协程。不受Pause影响,之后整帧仍交给task处理
+	OnData func(apdu *APDU)
+	//OnSignal 收到监视方向数据时按信息对象逐个调用,在读协程中按接收顺序调用,之后整帧仍交给task处理。
+	//信息对象地址、值、品质描述、传输原因和时标见Signal
+	OnSignal func(commonAddr uint16, s *Signal)
+	//OnStaleData 测量值(类型9、11、13、34~36)的品质描述词由当前值变为非当前值(NT=1)时调用,
+	//表示从站未能刷新该值,可将其置灰。在读协程中调用,同一信息对象持续为非当前值时不重复调用
+	OnStaleData func(commonAddr uint16, ioa uint32)
+	//OnTopicalData 调用过OnStaleData的信息对象再次收到当前值(NT=0)时调用
+	OnTopicalData func(commonAddr uint16, ioa uint32)
+	//OnFramingError 重新同步时丢弃的字节,用于诊断对端不是104服务器(如连接到HTTP端口)或使用101帧格式。
+	//收到下一帧、连接异常或累计丢弃256个字节时调用一次
+	OnFramingError func(skipped []byte)
+	//OnSequenceError 收到发送序号不连续或确认了未发送I帧的帧时调用,用于区分从站序号实现错误与链路问题
+	OnSequenceError func(detail SeqErrorDetail)
+	//OnTaskPanic Run的task发生panic时调用,r为recover的返回值。task在数据处理协程中按接收顺序依次调用,
+	//panic只影响该帧,读写协程和后续数据照常处理
+	OnTaskPanic func(r interface{}, apdu *APDU)
+	//PauseBuffer Pause期间最多缓存的数据帧数,超过时丢弃最早的帧,为0时丢弃暂停期间的全部数据
+	PauseBuffer int
+	//DataBuffer 等待交给task的数据队列长度,为0时为1024。task在数据处理协程中按接收顺序依次调用,
+	//处理缓慢时数据在队列中排队,读协程照常读取和确认
+	DataBuffer int
+	//DataOverflow 数据队列已满时的处理策略,默认OverflowDropOldest,丢弃的帧数见HealthStatus.DroppedFrames
+	DataOverflow OverflowPolicy
+	//ConfirmTimeout t1,发送启动激活后等待启动确认的超时时间,超时后重发,为0时使用15秒。
+	//已发送的I帧超过t1未被确认时断开重连
+	ConfirmTimeout time.Duration
+	//TestInterval t3,超过该时间未收到任何报文时发送测试激活帧(TESTFR_ACT),收到报文后重新计时。
+	//t3应大于t1(ConfirmTimeout),为0时使用20秒,为负数时不发送
+	TestInterval time.Duration
+	//TotalCallInterval 周期发送总召唤的间隔,与t3分别计时,每次建立连接后重新计时,为0时使用15分钟,为负数时不发送
+	TotalCallInterval time.Duration
+	//StartDtRetries 未收到启动确认时重发启动激活的次数,仍未收到时断开重连,为0时使用3
+	StartDtRetries int
+	//TerminationTimeout 激活确认后等待激活终止的最长时间,超时的命令在发送下一条命令时清除,
+	//不再出现在PendingCommands中,也不能再撤销,为0时使用5分钟
+	TerminationTimeout time.Duration
+	//TestMode 为true时发送的命令和召唤置传输原因的T位(试验),用于调试验收。收到的试验数据见ASDU.Test
+	TestMode bool
+	//CommandRate 每秒最多发送的控制命令数(选择和执行计为一条),为0时不限制。召唤、确认和测试帧不受限制
+	CommandRate float64
+	//CommandBurst 连续发送控制命令的最大数量(令牌桶容量),为0时使用1
+	CommandBurst int
+	//RejectRateLimited 为true时超过CommandRate的命令立即返回ErrRateLimited,否则等待,等待时间计入超时时间
+	RejectRateLimited bool
+}
+
+//commonAddr 自动召唤使用的公共地址
+func (c *Client) commonAddr() uint16 {
+	if c.Config.CommonAddr != 0 {
+		return c.Config.CommonAddr
+	}
+	return 1
+}
+
+//testInterval t3,为负数时不发送测试激活帧
+func (c *Client) testInterval() time.Duration {
+	if c.Config.TestInterval != 0 {
+		return c.Config.TestInterval
+	}
+	return testInterval
+}
+
+//totalCallInterval 周期总召唤的间隔,为负数时不发送
+func (c *Client) totalCallInterval() time.Duration {
+	if c.Config.TotalCallInterval != 0 {
+		return c.Config.TotalCallInterval
+	}
+	return totalCallInterval
+}
+
+//sendWindow 发送窗口k
+func (c *Client) sendWindow() int {
+	if c.Config.SendWindow > 0 {
+		return c.Config.SendWindow
+	}
+	return sendWindow
+}
+
+//confirmTimeout 等待启动确认的超时时间t1
+func (c *Client) confirmTimeout() time.Duration {
+	if c.Config.ConfirmTimeout > 0 {
+		return c.Config.ConfirmTimeout
+	}
+	return confirmTimeout
+}
+
+//startDtRetries 重发启动激活的次数
+func (c *Client) startDtRetries() int {
+	if c.Config.StartDtRetries > 0 {
+		return c.Config.StartDtRetries
+	}
+	return startDtRetries
+}
+
+//defaultQOI 默认的召唤限定词
+func (c *Client) defaultQOI() byte {
+	if c.Config.DefaultQOI != 0 {
+		return c.Config.DefaultQOI
+	}
+	return QoiStation
+}
+
+//defaultQCC 默认的计数量召唤命令限定词
+func (c *Client) defaultQCC() byte {
+	if c.Config.DefaultQCC != 0 {
+		return c.Config.DefaultQCC
+	}
+	return RqtGeneral | FrzRead
+}
+
+//defaultQRP 默认的复位进程命令限定词
+func (c *Client) defaultQRP() byte {
+	if c.Config.DefaultQRP != 0 {
+		return c.Config.DefaultQRP
+	}
+	return QrpGeneral
+}
+
+//DefaultQualifiers 返回实际使用的默认召唤限定词QOI、计数量召唤命令限定词QCC和复位进程命令限定词QRP
+func (c *Client) DefaultQualifiers() (qoi, qcc, qrp byte) {
+	return c.defaultQOI(), c.defaultQCC(), c.defaultQRP()
+}
+
+//clock 获取客户端使用的时钟
+func (c *Client) clock() Clock {
+	if c.Config.Clock != nil {
+		return c.Config.Clock
+	}
+	return defaultClock
+}
@@ -0,0 +1,64 @@
+package iec104
+
+import (
+	"context"
+	"fmt"
+)
+
+//计数量召唤命令限定词QCC,bit0~5为请求RQT,bit6~7为冻结FRZ
+const (
+	RqtGroup1  byte = 1 //请求第1组计数量
+	RqtGroup2  byte = 2 //请求第2组计数量
+	RqtGroup3  byte = 3 //请求第3组计数量
+	RqtGroup4  byte = 4 //请求第4组计数量
+	RqtGeneral byte = 5 //总的请求计数量
+
+	FrzRead        byte = 0 << 6 //读,不冻结不复位
+	FrzFreeze      byte = 1 << 6 //冻结不带复位
+	FrzFreezeReset byte = 2 << 6 //冻结带复位
+	FrzReset       byte = 3 << 6 //计数量复位
+)
+
+//counterCause 响应计数量召唤的传输原因,总请求为37,第1~4组为38~41
+func counterCause(rqt byte) Cause {
+	if rqt == RqtGeneral {
+		return CauseRequestByGeneralCounter
+	}
+	return CauseRequestByGeneralCounter + Cause(rqt)
+}
+
+//CounterInterrogate 向commonAddr发送计数量召唤命令(C_CI_NA_1),qcc为计数量召唤命令限定词(RQT|FRZ),
+//只收集与请求组对应传输原因(总请求37,第1~4组38~41)的累计量,返回召唤结束前上送的信息对象。
+//qcc为0时使用Config.DefaultQCC,与Interrogate相同同一公共地址的计数量召唤依次发送
+func (c *Client) CounterInterrogate(ctx context.Context, commonAddr uint16, qcc byte) ([]*Signal, error) {
+	if qcc == 0 {
+		qcc = c.defaultQCC()
+	}
+	rqt := qcc & 0x3f
+	if rqt < RqtGroup1 || rqt > RqtGeneral {
+		return nil, fmt.Errorf("计数量召唤请求[%d]非法", rqt)
+	}
+	return c.interrogate(ctx, CCiNa1, commonAddr, qcc, counterCause(rqt))
+}
+
+//ReadCounters 读commonAddr的计数量group(RqtGroup1~RqtGroup4或RqtGeneral),不冻结不复位,
+//不同组可按不同周期召唤,如频繁读取需量计数、每日读取电能计数
+func (c *Client) ReadCounters(ctx context.Context, commonAddr uint16, group byte) ([]*Signal, error) {
+	if group < RqtGroup1 || group > RqtGeneral {
+		return nil, fmt.Errorf("计数量召唤请求[%d]非法", group)
+	}
+	return c.CounterInterrogate(ctx, commonAddr, group|FrzRead)
+}
+
+//FreezeAndReadCounters 对commonAddr的计数量group(RqtGroup1~RqtGroup4或RqtGeneral)发送冻结带复位,
+//收到激活终止后再发送读计数量,返回召唤结束前上送的冻结值
+func (c *Client) FreezeAndReadCounters(ctx context.Context, commonAddr uint16, group byte) ([]*Signal, error) {
+	if group < RqtGroup1 || group > RqtGeneral {
+		return nil, fmt.Errorf("计数量召唤请求[%d]非法", group)
+	}
+	cause := counterCause(group)
+	if _, err := c.interrogate(ctx, CCiNa1, commonAddr, group|FrzFreezeReset, cause); err != nil {
+		return nil, err
+	}
+	return c.interrogate(ctx, CCiNa1, commonAddr, group|FrzRead, cause)
+}
@@ -0,0 +1,75 @@
+package iec104
+
+import "context"
+
+//StopDT 发送停止激活(STOPDT_ACT),等待停止确认。发送前先确认已收到的I帧(严格确认时除外),之后不再发送I帧,
+//命令和召唤返回ErrNotActive,直至StartDT
+func (c *Client) StopDT(ctx context.Context) error {
+	if !c.connected() {
+		return ErrNotConnected
+	}
+	c.setActive(false)
+	c.flushAck()
+	return c.waitCon(ctx, stopDtAct, stopDtCon)
+}
+
+//StartDT 停止数据传输后重新发送启动激活(STARTDT_ACT),等待启动确认。
+//发送序号和接收序号从0开始,清空未确认的I帧,收到启动确认后与建立连接时相同发送总召唤
+func (c *Client) StartDT(ctx context.Context) error {
+	if !c.connected() {
+		return ErrNotConnected
+	}
+	c.mu.Lock()
+	if c.active {
+		c.mu.Unlock()
+		return nil
+	}
+	c.resetSeq()
+	c.mu.Unlock()
+	return c.waitCon(ctx, startDtAct, startDtCon)
+}
+
+//resetSeq 发送序号、接收序号和未确认的I帧清零,调用方需持有mu
+func (c *Client) resetSeq() {
+	c.rsn = 0
+	c.ssn = 0
+	c.unacked = 0
+	c.ackTimer = false
+	c.peerAck = 0
+	c.ackedRsn = 0
+	c.unackedSent = nil
+	c.saveSeq()
+	c.grantSend()
+}
+
+//waitCon 发送U帧act并等待确认con
+func (c *Client) waitCon(ctx context.Context, act, con [4]byte) error {
+	ch := make(chan struct{})
+	c.mu.Lock()
+	connCtx := c.connCtx
+	if connCtx == nil || connCtx.Err() != nil {
+		c.mu.Unlock()
+		return ErrNotConnected
+	}
+	c.conChans[con] = append(c.conChans[con], ch)
+	c.mu.Unlock()
+	c.sendUFrame(act)
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-connCtx.Done():
+		return ErrNotConnected
+	}
+}
+
+//notifyCon 收到U帧确认con时通知所有等待的waitCon
+func (c *Client) notifyCon(con [4]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ch := range c.conChans[con] {
+		close(ch)
+	}
+	delete(c.conChans, con)
+}
@@ -0,0 +1,80 @@
+package iec104
+
+import (
+	"fmt"
+	"sync"
+)
+
+//ElementDecoder 私有类型信息元素的解析函数。
+//element为信息对象地址之后的剩余报文,引用复用的读缓冲区,需要保留时复制。返回解析出的信号和消耗的字节数,
+//TypeID、Address和Cause由解析器填充,结构化的值可放在Signal.Data中
+type ElementDecoder func(element []byte) (s *Signal, n int, err error)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = make(map[byte]ElementDecoder)
+)
+
+//RegisterElementDecoder 注册私有范围类型标识(128~255)的解析函数,重复注册时覆盖
+func RegisterElementDecoder(typeID byte, decoder ElementDecoder) error {
+	if typeID < 128 {
+		return fmt.Errorf("类型标识[%d]不在私有范围128~255内", typeID)
+	}
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	if decoder == nil {
+		delete(decoders, typeID)
+		return nil
+	}
+	decoders[typeID] = decoder
+	return nil
+}
+
+//lookupElementDecoder 查找已注册的解析函数
+func lookupElementDecoder(typeID byte) (ElementDecoder, bool) {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	decoder, ok := decoders[typeID]
+	return decoder, ok
+}
+
+//parseCustom 使用注册的解析函数解析私有类型的信息对象
+func (asdu *ASDU) parseCustom(asduBytes []byte, decoder ElementDecoder, n int) ([]*Signal, error) {
+	signals := make([]*Signal, 0, n)
+	offset := 6
+	var address uint32
+	for i := 0; i < n; i++ {
+		start := offset
+		if !asdu.Sequence || i == 0 {
+			if offset+3 > len(asduBytes) {
+				return asdu.objectError(signals, i, start, fmt.Errorf("asdu类型[%d]第%d个信息对象地址不完整", asdu.TypeID, i+1))
+			}
+			address = decodeIOA(asduBytes[offset:])
+			offset += 3
+		} else {
+			address++
+		}
+		s, size, err := decoder(asduBytes[offset:])
+		if err != nil {
+			return asdu.objectError(signals, i, start, fmt.Errorf("asdu类型[%d]第%d个信息对象解析异常: %v", asdu.TypeID, i+1, err))
+		}
+		if s == nil || size <= 0 || offset+size > len(asduBytes) {
+			return asdu.objectError(signals, i, start, fmt.Errorf("asdu类型[%d]第%d个信息对象解析结果非法", asdu.TypeID, i+1))
+		}
+		s.TypeID = uint(asdu.TypeID)
+		s.Address = address
+		s.Cause = asdu.Cause
+		offset += size
+		signals = append(signals, s)
+	}
+	return signals, nil
+}
+
+//objectError 宽松解析时保留已解析的信息对象并记录ObjectError,否则返回err
+func (asdu *ASDU) objectError(signals []*Signal, index, offset int, err error) ([]*Signal, error) {
+	if !LenientParse {
+		return nil, err
+	}
+	asdu.ObjectError = &ObjectError{Index: index, Offset: offset, Err: err}
+	return signals, nil
+}
@@ -0,0 +1,53 @@
+package iec104
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+func TestRegisterElementDecoder(t *testing.T) {
+	//私有类型200:2个字节的值加1个字节的状态
+	decoder := func(element []byte) (*Signal, int, error) {
+		if len(element) < 3 {
+			return nil, 0, fmt.Errorf("长度不足")
+		}
+		return &Signal{
+			Value: float64(binary.LittleEndian.Uint16(element[:2])),
+			Data:  map[string]byte{"status": element[2]},
+		}, 3, nil
+	}
+	if err := RegisterElementDecoder(200, decoder); err != nil {
+		t.Fatalf("RegisterElementDecoder() error = %v", err)
+	}
+	defer RegisterElementDecoder(200, nil)
+	if err := RegisterElementDecoder(100, decoder); err == nil {
+		t.Error("RegisterElementDecoder() 标准类型应返回错误")
+	}
+	tests := []struct {
+		name      string
+		asduBytes []byte
+		want      []uint32
+		wantErr   bool
+	}{
+		{"测试私有类型，sq=false", []byte{0xC8, 0x02, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x10, 0x00, 0x01, 0x05, 0x00, 0x00, 0x20, 0x00, 0x00}, []uint32{1, 5}, false},
+		{"测试私有类型，sq=true", []byte{0xC8, 0x82, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x10, 0x00, 0x01, 0x20, 0x00, 0x00}, []uint32{1, 2}, false},
+		{"测试私有类型长度不足", []byte{0xC8, 0x02, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x10, 0x00, 0x01, 0x05, 0x00}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signals, err := new(ASDU).ParseASDU(tt.asduBytes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ASDU.ParseASDU() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(signals) != len(tt.want) {
+				t.Fatalf("ASDU.ParseASDU() got %d signals, want %d", len(signals), len(tt.want))
+			}
+			for i, s := range signals {
+				if s.Address != tt.want[i] || s.TypeID != 200 || s.Data == nil {
+					t.Errorf("ASDU.ParseASDU() signal[%d] = %+v", i, s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package iec104
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+//文件状态SOF
+const (
+	//sofLFD 目录中最后一个文件
+	sofLFD = 0x20
+	//sofFOR 名称定义子目录
+	sofFOR = 0x40
+	//sofFA 文件传输已激活
+	sofFA = 0x80
+)
+
+//FileInfo 目录(F_DR_TA_1)中的一个文件
+type FileInfo struct {
+	Address uint32    `json:"address"` //信息对象地址
+	Name    uint16    `json:"name"`    //文件名称NOF
+	Length  uint32    `json:"length"`  //文件长度LOF,3个字节
+	Status  byte      `json:"status"`  //文件状态SOF,低5位为状态,高3位为LFD、FOR、FA
+	Time    time.Time `json:"time"`    //文件创建时间
+}
+
+//LastFile 是否为目录中最后一个文件(SOF的LFD位)
+func (f *FileInfo) LastFile() bool {
+	return f.Status&sofLFD == sofLFD
+}
+
+//IsDirectory 名称是否定义子目录(SOF的FOR位)
+func (f *FileInfo) IsDirectory() bool {
+	return f.Status&sofFOR == sofFOR
+}
+
+//Active 文件传输是否已激活(SOF的FA位)
+func (f *FileInfo) Active() bool {
+	return f.Status&sofFA == sofFA
+}
+
+//parseFileInfo 解析目录的信息元素,文件存入s.Data,Value为文件长度
+func (s *Signal) parseFileInfo(element []byte) {
+	f := &FileInfo{
+		Address: s.Address,
+		Name:    binary.LittleEndian.Uint16(element[:2]),
+		Length:  uint32(element[2]) | uint32(element[3])<<8 | uint32(element[4])<<16,
+		Status:  element[5],
+	}
+	f.Time, _ = ParseCP56Time2a(element[6:13])
+	s.Value = float64(f.Length)
+	s.Quality = f.Status
+	s.setTime(element[6:13])
+	s.Data = f
+}
+
+//ParseDirectory 返回目录报文(F_DR_TA_1)中的文件,其他类型返回nil
+func ParseDirectory(apdu *APDU) []FileInfo {
+	if apdu == nil || apdu.ASDU == nil || apdu.ASDU.TypeID != FDrTa1 {
+		return nil
+	}
+	files := make([]FileInfo, 0, len(apdu.Signals))
+	for _, s := range apdu.Signals {
+		if f, ok := s.Data.(*FileInfo); ok {
+			files = append(files, *f)
+		}
+	}
+	return files
+}
+
+//CallDirectory 发送召唤目录命令(F_SC_NA_1,传输原因13文件传输),从站以目录报文(F_DR_TA_1)响应,
+//响应报文交给task,可用ParseDirectory解析
+func (c *Client) CallDirectory(commonAddr uint16) error {
+	//文件名称NOF、节名称NOS和选择召唤限定词SCQ均为0
+	data, err := c.sendIFrame(encodeCommandASDU(FScNa1, c.cause(CauseFileTransfer), c.Config.Originator, commonAddr, 0, []byte{0, 0, 0, 0}))
+	if err != nil {
+		return err
+	}
+	c.Logger.Debugf("发送召唤目录命令,公共地址:%d: [% X]", commonAddr, data)
+	return nil
+}
@@ -0,0 +1,159 @@
+package iec104
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+//maxAPDULen APDU长度的最大值,不含起始符和长度
+const maxAPDULen = 253
+
+//readBufferSize 读缓冲区大小,可容纳多个最大长度的帧,减少系统调用
+const readBufferSize = 4 * (2 + maxAPDULen)
+
+//maxSkippedChunk 丢弃的字节累计达到该数量时调用一次onSkipped,避免长时间无法同步时无限缓存
+const maxSkippedChunk = 256
+
+//TruncatedFrameError 收到的字节少于长度字段时连接断开或读超时,用于区分对端发送不完整的帧与正常断开
+type TruncatedFrameError struct {
+	Expected int    //按长度字段应收到的字节数,包含起始符和长度
+	Received int    //实际收到的字节数
+	Frame    []byte //收到的部分帧
+	Err      error  //读取异常,通常为io.ErrUnexpectedEOF
+}
+
+//Error 实现error接口,收到完整的ASDU报文头时包含类型标识、传输原因和公共地址
+func (e *TruncatedFrameError) Error() string {
+	msg := fmt.Sprintf("帧不完整,应为%d个字节,实际收到%d个字节[% X]", e.Expected, e.Received, e.Frame)
+	if typeID, _, _, cause, commonAddr, err := PeekASDUHeader(e.Frame); err == nil {
+		msg += fmt.Sprintf(",类型:%s,传输原因:%d,公共地址:%d", TypeIDName(typeID), cause, commonAddr)
+	}
+	return fmt.Sprintf("%s: %v", msg, e.Err)
+}
+
+//Unwrap 返回读取异常
+func (e *TruncatedFrameError) Unwrap() error {
+	return e.Err
+}
+
+//truncatedFrame 长度字段为frame[1]的帧只收到前n个字节(包含起始符和长度)
+func truncatedFrame(frame []byte, n int, err error) *TruncatedFrameError {
+	return &TruncatedFrameError{Expected: len(frame), Received: n, Frame: append([]byte(nil), frame[:n]...), Err: err}
+}
+
+//frameReader 从字节流中读取APDU帧。104没有校验和,丢失或多出一个字节后,
+//跳过字节直至下一个起始符且长度合法,重新同步,避免断开重连
+type frameReader struct {
+	r       io.Reader
+	pending []byte //重新同步时退回的字节,优先于r读取
+	skipped int    //重新同步丢弃的字节数,下一帧读取后清零
+	//onSkipped 不为nil时记录丢弃的字节,读取到下一帧、读取异常或累计maxSkippedChunk个字节时调用
+	onSkipped func(skipped []byte)
+	discarded []byte
+	buf       [2 + maxAPDULen]byte //每帧复用的缓冲区,避免高帧率时每帧分配
+	one       [1]byte
+}
+
+//newFrameReader 创建帧读取器,r包装为带缓冲的读取器,缓冲区为空时只读一次r,
+//已到达的数据立即返回,不等待填满缓冲区,r的读超时同样有效
+func newFrameReader(r io.Reader) *frameReader {
+	return &frameReader{r: bufio.NewReaderSize(r, readBufferSize)}
+}
+
+//readFrame 读取下一帧,包含起始符和长度,skipped为此前丢弃的字节数。帧不完整时返回*TruncatedFrameError。
+//frame引用复用的缓冲区,只在下次调用readFrame前有效,需要保留时复制
+func (fr *frameReader) readFrame() (frame []byte, skipped int, err error) {
+	for {
+		b, err := fr.readByte()
+		if err != nil {
+			fr.flushSkipped()
+			return nil, fr.skipped, err
+		}
+		if b != startFrame {
+			fr.skip(b)
+			continue
+		}
+		length, err := fr.readByte()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			fr.flushSkipped()
+			return nil, fr.skipped, err
+		}
+		if length < 4 || length > maxAPDULen {
+			//长度非法,起始符为噪声,长度字节可能是下一帧的起始符
+			fr.skip(startFrame)
+			fr.unread([]byte{length})
+			continue
+		}
+		frame = fr.buf[:2+int(length)]
+		frame[0], frame[1] = startFrame, length
+		if n, err := fr.readFull(frame[2:]); err != nil {
+			fr.flushSkipped()
+			return nil, fr.skipped, truncatedFrame(frame, 2+n, err)
+		}
+		fr.flushSkipped()
+		skipped = fr.skipped
+		fr.skipped = 0
+		return frame, skipped, nil
+	}
+}
+
+//resync 丢弃解析失败的帧的起始符,从其后的字节重新查找起始符
+func (fr *frameReader) resync(frame []byte) {
+	fr.skip(frame[0])
+	fr.unread(frame[1:])
+}
+
+//skip 丢弃一个字节
+func (fr *frameReader) skip(b byte) {
+	fr.skipped++
+	if fr.onSkipped == nil {
+		return
+	}
+	fr.discarded = append(fr.discarded, b)
+	if len(fr.discarded) >= maxSkippedChunk {
+		fr.flushSkipped()
+	}
+}
+
+//flushSkipped 将记录的丢弃字节交给onSkipped
+func (fr *frameReader) flushSkipped() {
+	if len(fr.discarded) == 0 {
+		return
+	}
+	discarded := fr.discarded
+	fr.discarded = nil
+	fr.onSkipped(discarded)
+}
+
+func (fr *frameReader) readByte() (byte, error) {
+	if len(fr.pending) > 0 {
+		b := fr.pending[0]
+		fr.pending = fr.pending[1:]
+		return b, nil
+	}
+	_, err := io.ReadFull(fr.r, fr.one[:])
+	return fr.one[0], err
+}
+
+//readFull 读满p,返回读取的字节数
+func (fr *frameReader) readFull(p []byte) (int, error) {
+	n := copy(p, fr.pending)
+	fr.pending = fr.pending[n:]
+	if n == len(p) {
+		return n, nil
+	}
+	m, err := io.ReadFull(fr.r, p[n:])
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n + m, err
+}
+
+//unread 退回字节,下次读取时最先返回
+func (fr *frameReader) unread(b []byte) {
+	fr.pending = append(append(make([]byte, 0, len(b)+len(fr.pending)), b...), fr.pending...)
+}
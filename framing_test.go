@@ -0,0 +1,165 @@
+package iec104
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_frameReader_readFrame(t *testing.T) {
+	uFrame := []byte{0x68, 0x04, 0x0B, 0x00, 0x00, 0x00}
+	sFrame := []byte{0x68, 0x04, 0x01, 0x00, 0x02, 0x00}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	tests := []struct {
+		name        string
+		input       []byte
+		want        [][]byte
+		wantSkipped []int
+	}{
+		{"测试正常帧", join(uFrame, sFrame), [][]byte{uFrame, sFrame}, []int{0, 0}},
+		{"测试帧间插入1个字节", join(uFrame, []byte{0x00}, sFrame), [][]byte{uFrame, sFrame}, []int{0, 1}},
+		{"测试插入起始符和非法长度", join(uFrame, []byte{0x68, 0xff}, sFrame), [][]byte{uFrame, sFrame}, []int{0, 2}},
+		{"测试开头为半帧", join(sFrame[3:], uFrame), [][]byte{uFrame}, []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := newFrameReader(bytes.NewReader(tt.input))
+			for i := range tt.want {
+				frame, skipped, err := fr.readFrame()
+				if err != nil {
+					t.Fatalf("frameReader.readFrame() error = %v", err)
+				}
+				if !reflect.DeepEqual(frame, tt.want[i]) || skipped != tt.wantSkipped[i] {
+					t.Errorf("frameReader.readFrame() = [% X], %d, want [% X], %d", frame, skipped, tt.want[i], tt.wantSkipped[i])
+				}
+			}
+			if _, _, err := fr.readFrame(); err != io.EOF {
+				t.Errorf("frameReader.readFrame() error = %v, want EOF", err)
+			}
+		})
+	}
+}
+
+func Test_frameReader_resync(t *testing.T) {
+	//第一帧内多出1个字节,第一帧解析失败后从其后重新同步
+	input := []byte{0x68, 0x04, 0x0B, 0x00, 0x00, 0x00, 0x00, 0x68, 0x04, 0x01, 0x00, 0x02, 0x00}
+	fr := newFrameReader(bytes.NewReader(input))
+	frame, _, err := fr.readFrame()
+	if err != nil {
+		t.Fatalf("frameReader.readFrame() error = %v", err)
+	}
+	fr.resync(frame)
+	frame, skipped, err := fr.readFrame()
+	if err != nil {
+		t.Fatalf("frameReader.readFrame() error = %v", err)
+	}
+	if !reflect.DeepEqual(frame, input[7:]) || skipped != 7 {
+		t.Errorf("frameReader.readFrame() = [% X], %d, want [% X], 7", frame, skipped, input[7:])
+	}
+}
+
+func Test_frameReader_onSkipped(t *testing.T) {
+	uFrame := []byte{0x68, 0x04, 0x0B, 0x00, 0x00, 0x00}
+	http := []byte("HTTP/1.1 400 Bad Request\r\n")
+	tests := []struct {
+		name  string
+		input []byte
+		want  [][]byte
+	}{
+		{"测试HTTP响应", append(append([]byte{}, http...), uFrame...), [][]byte{http}},
+		{"测试插入起始符和非法长度", append([]byte{0x68, 0xff}, uFrame...), [][]byte{{0x68, 0xff}}},
+		{"测试超过256个字节分段", append(make([]byte, 300), uFrame...), [][]byte{make([]byte, 256), make([]byte, 44)}},
+		{"测试连接断开", []byte{0x01, 0x02}, [][]byte{{0x01, 0x02}}},
+		{"测试正常帧", uFrame, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]byte
+			fr := newFrameReader(bytes.NewReader(tt.input))
+			fr.onSkipped = func(skipped []byte) { got = append(got, skipped) }
+			for {
+				if _, _, err := fr.readFrame(); err != nil {
+					break
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("丢弃的字节 = [% X], want [% X]", got, tt.want)
+			}
+		})
+	}
+}
+
+//countingReader 循环读取同一帧并统计Read调用次数,模拟每次Read一次系统调用
+type countingReader struct {
+	frame []byte
+	off   int
+	reads int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	r.reads++
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], r.frame[r.off:])
+		n += c
+		r.off = (r.off + c) % len(r.frame)
+	}
+	return n, nil
+}
+
+func Benchmark_frameReader_readFrame(b *testing.B) {
+	frame := convertBytes([]byte{0x00, 0x00, 0x02, 0x00, 0x0d, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x40, 0x00, 0x00, 0x00, 0x80, 0x3f, 0x00})
+	tests := []struct {
+		name      string
+		newReader func(r io.Reader) *frameReader
+	}{
+		{"无缓冲", func(r io.Reader) *frameReader { return &frameReader{r: r} }},
+		{"bufio", newFrameReader},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			src := &countingReader{frame: frame}
+			fr := tt.newReader(src)
+			b.SetBytes(int64(len(frame)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := fr.readFrame(); err != nil {
+					b.Fatalf("frameReader.readFrame() error = %v", err)
+				}
+			}
+			b.ReportMetric(float64(src.reads)/float64(b.N), "reads/frame")
+		})
+	}
+}
+
+func Test_frameReader_truncated(t *testing.T) {
+	single := []byte{0x68, 0x0E, 0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01}
+	tests := []struct {
+		name         string
+		input        []byte
+		wantReceived int
+		wantHeader   bool
+	}{
+		{"测试只收到控制域", single[:6], 6, false},
+		{"测试收到完整的ASDU报文头", single[:13], 13, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := newFrameReader(bytes.NewReader(tt.input)).readFrame()
+			te, ok := err.(*TruncatedFrameError)
+			if !ok {
+				t.Fatalf("frameReader.readFrame() error = %v, want *TruncatedFrameError", err)
+			}
+			if te.Expected != len(single) || te.Received != tt.wantReceived || te.Err != io.ErrUnexpectedEOF {
+				t.Errorf("TruncatedFrameError = %+v, want expected %d received %d", te, len(single), tt.wantReceived)
+			}
+			if got := strings.Contains(te.Error(), "公共地址:1"); got != tt.wantHeader {
+				t.Errorf("TruncatedFrameError.Error() = %v, want 包含ASDU报文头 %v", te.Error(), tt.wantHeader)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+//go:build go1.18
+// +build go1.18
+
+package iec104
+
+import "testing"
+
+func FuzzParseAPDU(f *testing.F) {
+	seeds := [][]byte{
+		{0x0B, 0x00, 0x00, 0x00},
+		{0x01, 0x00, 0x02, 0x00},
+		{0x00, 0x00, 0x02, 0x00, 0x01, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01},
+		{0x00, 0x00, 0x02, 0x00, 0x0D, 0x82, 0x14, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x80, 0x3F, 0x00, 0x00, 0x00, 0x00, 0x40, 0x00},
+		{0x00, 0x00, 0x02, 0x00, 0x2D, 0x81, 0x07, 0x00, 0x01, 0x00},
+		{0x00, 0x00, 0x02, 0x00, 0x64, 0x80, 0x07, 0x00, 0x01, 0x00},
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input []byte) {
+		apdu := new(APDU)
+		if err := apdu.parseAPDU(input); err == nil && apdu.APCI == nil {
+			t.Errorf("APDU.parseAPDU([% X]) 解析成功但APCI为nil", input)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package iec104
+
+import (
+	"net"
+	"time"
+)
+
+//HealthStatus 连接健康状态快照,可直接序列化为json供就绪探针使用
+type HealthStatus struct {
+	Connected      bool          `json:"connected"`        //是否已建立tcp连接
+	Active         bool          `json:"active"`           //是否已收到启动确认
+	SinceLastFrame time.Duration `json:"since_last_frame"` //距最近一次收到帧的时间,从未收到时为0
+	Unacknowledged int           `json:"unacknowledged"`   //已发送未被确认的I帧数量
+	LastError      string        `json:"last_error,omitempty"`
+	Reconnects     int           `json:"reconnects"`      //建立首次连接后的重连次数
+	RejectedFrames int           `json:"rejected_frames"` //因信息对象不在白名单中丢弃的帧数
+	FilteredFrames int           `json:"filtered_frames"` //按类型标识过滤丢弃的帧数
+	DroppedFrames  int           `json:"dropped_frames"`  //数据队列已满时按Config.DataOverflow丢弃的帧数
+	DecodeErrors   int           `json:"decode_errors"`   //ASDU无法解析(如不支持的类型标识)而丢弃的I帧数,这些帧仍被确认
+}
+
+//Health 返回连接健康状态
+func (c *Client) Health() HealthStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := HealthStatus{
+		Connected:      c.connCtx != nil && c.connCtx.Err() == nil,
+		Unacknowledged: c.outstanding(),
+	}
+	h.Active = h.Connected && c.active
+	if !c.lastRecv.IsZero() {
+		h.SinceLastFrame = c.clock().Now().Sub(c.lastRecv)
+	}
+	if c.lastErr != nil {
+		h.LastError = c.lastErr.Error()
+	}
+	h.RejectedFrames = c.rejectedFrames
+	h.FilteredFrames = c.filteredFrames
+	h.DroppedFrames = c.droppedFrames
+	h.DecodeErrors = c.decodeErrors
+	if c.connects > 1 {
+		h.Reconnects = c.connects - 1
+	}
+	return h
+}
+
+//LastSeen 返回当前连接最近一次收到公共地址为commonAddr的I帧的时间,重连后重新记录
+func (c *Client) LastSeen(commonAddr uint16) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.lastSeen[commonAddr]
+	return t, ok
+}
+
+//SeqState 返回当前连接的发送序号ssn、接收序号rsn、对端确认的接收序号(ackedSeq之前的I帧均已被确认)
+//和已发送未被确认的I帧数量,用于排查k窗口占满导致的停发
+func (c *Client) SeqState() (sendSeq, recvSeq, ackedSeq uint16, outstanding int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return uint16(c.ssn), uint16(c.rsn), uint16(c.peerAck), c.outstanding()
+}
+
+//RemoteAddr 返回当前连接的远端地址,即实际连接的服务器(主备切换或负载均衡后的地址),未连接时返回nil
+func (c *Client) RemoteAddr() net.Addr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.connCtx == nil || c.connCtx.Err() != nil {
+		return nil
+	}
+	return c.conn.RemoteAddr()
+}
+
+//LocalAddr 返回当前连接的本地地址,未连接时返回nil
+func (c *Client) LocalAddr() net.Addr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.connCtx == nil || c.connCtx.Err() != nil {
+		return nil
+	}
+	return c.conn.LocalAddr()
+}
+
+//outstanding 已发送未被确认的I帧数量,调用方需持有mu
+func (c *Client) outstanding() int {
+	return (int(c.ssn) - int(c.peerAck) + 1<<15) % (1 << 15)
+}
+
+//setLastErr 记录最近一次异常
+func (c *Client) setLastErr(err error) {
+	c.mu.Lock()
+	c.lastErr = err
+	c.mu.Unlock()
+}
@@ -0,0 +1,273 @@
+package iec104
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+//召唤限定词QOI,21~36为第1~16组召唤
+const (
+	QoiStation byte = 20 //站召唤(总召唤)
+	QoiGroup1  byte = 21 //第1组召唤
+)
+
+//BroadcastCommonAddr 全局公共地址,召唤该站所有公共地址
+const BroadcastCommonAddr uint16 = 0xFFFF
+
+//ErrInterrogationInProgress 该公共地址已有召唤正在进行,Config.RejectConcurrentInterrogation为true时返回
+var ErrInterrogationInProgress = errors.New("该公共地址已有召唤正在进行")
+
+//ErrInterrogationIncomplete 超过Config.InterrogationTimeout未收到召唤结束,返回的异常包装该错误,可用errors.Is判断
+var ErrInterrogationIncomplete = errors.New("未收到召唤结束")
+
+//interrogationKey 公共地址+召唤命令类型,总召唤和计数量召唤可同时进行
+type interrogationKey struct {
+	commonAddr uint16
+	typeID     byte
+}
+
+//interrogation 正在进行的召唤
+type interrogation struct {
+	qualifier byte  //召唤限定词QOI或计数量召唤命令限定词QCC
+	cause     Cause //响应召唤上送信息对象的传输原因
+	signals   []*Signal
+	byAddr    map[uint16][]*Signal //全局召唤按公共地址分组的信息对象,其他召唤为nil
+	remaining map[uint16]bool      //全局召唤尚未收到召唤结束的公共地址,为nil时等待全局公共地址的召唤结束
+	done      chan error
+	finished  chan struct{} //召唤结束并从interrogations中删除后关闭,排队的召唤开始发送
+	auto      bool          //自动召唤,只等待召唤结束,不收集信息对象
+}
+
+//Interrogate 向commonAddr发送召唤命令(C_IC_NA_1),返回召唤结束前上送的信息对象。
+//站内没有数据时只收到召唤确认和召唤结束,返回空切片。超过Config.InterrogationTimeout未收到召唤结束时
+//返回已收到的信息对象和包装ErrInterrogationIncomplete的异常。
+//104规约不允许对同一站同时进行多个召唤,各组召唤上送的信息对象只能按传输原因区分,
+//因此同一公共地址的召唤依次发送,包括连接后和周期自动发送的总召唤,排队等待的时间受ctx控制。qoi为0时使用Config.DefaultQOI。
+//commonAddr为BroadcastCommonAddr时与InterrogateAll相同,返回按公共地址排序合并后的信息对象
+func (c *Client) Interrogate(ctx context.Context, commonAddr uint16, qoi byte) ([]*Signal, error) {
+	if qoi == 0 {
+		qoi = c.defaultQOI()
+	}
+	if commonAddr == BroadcastCommonAddr {
+		byAddr, err := c.InterrogateAll(ctx, qoi)
+		if byAddr == nil {
+			return nil, err
+		}
+		addrs := make([]int, 0, len(byAddr))
+		for addr := range byAddr {
+			addrs = append(addrs, int(addr))
+		}
+		sort.Ints(addrs)
+		signals := make([]*Signal, 0)
+		for _, addr := range addrs {
+			signals = append(signals, byAddr[uint16(addr)]...)
+		}
+		return signals, err
+	}
+	return c.interrogate(ctx, CIcNa1, commonAddr, qoi, Cause(qoi))
+}
+
+//InterrogateAll 以全局公共地址(0xFFFF)发送召唤命令,返回按公共地址分组的信息对象,用于一个连接下有多个公共地址的网关。
+//配置了Config.CommonAddrs时收到其中每个公共地址的召唤结束后返回,否则收到全局公共地址的召唤结束后返回;
+//ctx结束或超过Config.InterrogationTimeout时返回已收到的信息对象和异常。qoi为0时使用Config.DefaultQOI
+func (c *Client) InterrogateAll(ctx context.Context, qoi byte) (map[uint16][]*Signal, error) {
+	if qoi == 0 {
+		qoi = c.defaultQOI()
+	}
+	it := newInterrogation(qoi, Cause(qoi))
+	it.byAddr = make(map[uint16][]*Signal)
+	if len(c.Config.CommonAddrs) > 0 {
+		it.remaining = make(map[uint16]bool, len(c.Config.CommonAddrs))
+		for _, addr := range c.Config.CommonAddrs {
+			it.remaining[addr] = true
+		}
+	}
+	err := c.runInterrogation(ctx, CIcNa1, BroadcastCommonAddr, it)
+	if err != nil && err != ctx.Err() && !errors.Is(err, ErrInterrogationIncomplete) {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	byAddr := make(map[uint16][]*Signal, len(it.byAddr))
+	for addr, signals := range it.byAddr {
+		byAddr[addr] = signals
+	}
+	return byAddr, err
+}
+
+//newInterrogation 限定词为qualifier、收集传输原因为cause的召唤
+func newInterrogation(qualifier byte, cause Cause) *interrogation {
+	return &interrogation{
+		qualifier: qualifier,
+		cause:     cause,
+		signals:   make([]*Signal, 0),
+		done:      make(chan error, 1),
+		finished:  make(chan struct{}),
+	}
+}
+
+//stationDone 收到commonAddr的召唤结束或否定确认,返回召唤是否结束,调用方需持有mu
+func (it *interrogation) stationDone(commonAddr uint16) bool {
+	if it.byAddr == nil || commonAddr == BroadcastCommonAddr {
+		return true
+	}
+	delete(it.remaining, commonAddr)
+	return it.remaining != nil && len(it.remaining) == 0
+}
+
+//interrogate 发送召唤命令,收集传输原因为cause的信息对象直至召唤结束,超时未收到召唤结束时返回已收到的信息对象
+func (c *Client) interrogate(ctx context.Context, typeID byte, commonAddr uint16, qualifier byte, cause Cause) ([]*Signal, error) {
+	it := newInterrogation(qualifier, cause)
+	err := c.runInterrogation(ctx, typeID, commonAddr, it)
+	if err != nil && !errors.Is(err, ErrInterrogationIncomplete) {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return it.signals, err
+}
+
+//runInterrogation 登记并发送召唤it,等待召唤结束
+func (c *Client) runInterrogation(ctx context.Context, typeID byte, commonAddr uint16, it *interrogation) error {
+	if !c.connected() {
+		return ErrNotConnected
+	}
+	key := interrogationKey{commonAddr, typeID}
+	if err := c.queueInterrogation(ctx, key, it); err != nil {
+		return err
+	}
+	defer c.endInterrogation(key, it)
+	data, err := c.sendIFrame(encodeCommandASDU(typeID, c.cause(CauseActivation), c.Config.Originator, commonAddr, 0, []byte{it.qualifier}))
+	if err != nil {
+		return err
+	}
+	c.Logger.Debugf("发送召唤,类型:%s,公共地址:%d,限定词:%d: [% X]", TypeIDName(typeID), commonAddr, it.qualifier, data)
+	var timeout <-chan time.Time
+	if d := c.Config.InterrogationTimeout; d > 0 {
+		timeout = c.clock().After(d)
+	}
+	select {
+	case err := <-it.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timeout:
+		c.Logger.Warnf("召唤超过%v未收到召唤结束,类型:%s,公共地址:%d", c.Config.InterrogationTimeout, TypeIDName(typeID), commonAddr)
+		return fmt.Errorf("%w,类型:%s,公共地址:%d,超时时间:%v", ErrInterrogationIncomplete, TypeIDName(typeID), commonAddr, c.Config.InterrogationTimeout)
+	}
+}
+
+//queueInterrogation 等待同一公共地址正在进行的召唤结束后登记it,
+//Config.RejectConcurrentInterrogation为true时不等待,返回ErrInterrogationInProgress
+func (c *Client) queueInterrogation(ctx context.Context, key interrogationKey, it *interrogation) error {
+	for {
+		c.mu.Lock()
+		prev, ok := c.interrogations[key]
+		if !ok {
+			c.interrogations[key] = it
+			c.mu.Unlock()
+			return nil
+		}
+		c.mu.Unlock()
+		if c.Config.RejectConcurrentInterrogation {
+			return ErrInterrogationInProgress
+		}
+		select {
+		case <-prev.finished:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.ctx.Done():
+			return ErrNotConnected
+		}
+	}
+}
+
+//endInterrogation 召唤结束,从interrogations中删除it,排队的召唤开始发送
+func (c *Client) endInterrogation(key interrogationKey, it *interrogation) {
+	c.mu.Lock()
+	if c.interrogations[key] == it {
+		delete(c.interrogations, key)
+	}
+	c.mu.Unlock()
+	close(it.finished)
+}
+
+//handleInterrogationResponse 处理召唤确认和召唤结束,限定词与召唤命令不一致时忽略。
+//没有该公共地址的召唤时交给正在进行的全局召唤,全局召唤中单个公共地址的否定确认只结束该公共地址
+func (c *Client) handleInterrogationResponse(apdu *APDU) {
+	asdu := apdu.ASDU
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	it, ok := c.interrogations[interrogationKey{asdu.PublicAddress, asdu.TypeID}]
+	if !ok {
+		it, ok = c.interrogations[interrogationKey{BroadcastCommonAddr, asdu.TypeID}]
+	}
+	if !ok || len(apdu.Signals) == 0 || byte(apdu.Signals[0].Value) != it.qualifier {
+		return
+	}
+	var err error
+	switch asdu.Cause {
+	case CauseActivationCon:
+		if !asdu.Negative {
+			return
+		}
+		err = ErrCommandRejected
+	case CauseActivationTermination:
+	case CauseUnknownTypeID, CauseUnknownCause, CauseUnknownCommonAddr, CauseUnknownIOA:
+		err = fmt.Errorf("召唤被拒绝,传输原因:%d", asdu.Cause)
+	default:
+		return
+	}
+	if it.byAddr != nil && asdu.PublicAddress != BroadcastCommonAddr {
+		c.Logger.Debugf("全局召唤中公共地址%d结束: %v", asdu.PublicAddress, err)
+		err = nil
+	}
+	if it.stationDone(asdu.PublicAddress) {
+		notify(it.done, err)
+	}
+}
+
+//collectInterrogation 收集响应召唤上送的信息对象,只收集公共地址和传输原因与召唤一致的监视方向I帧。
+//召唤期间收到的S帧和U帧(如对端的测试激活)照常处理,不计入召唤数据,也不结束召唤
+func (c *Client) collectInterrogation(apdu *APDU) {
+	if apdu.ASDU == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	commonAddr := apdu.ASDU.PublicAddress
+	for _, typeID := range []byte{CIcNa1, CCiNa1} {
+		it, ok := c.interrogations[interrogationKey{commonAddr, typeID}]
+		if ok && !it.auto && apdu.ASDU.Cause == it.cause {
+			it.collect(commonAddr, apdu.Signals)
+		}
+		if commonAddr == BroadcastCommonAddr {
+			continue
+		}
+		it, ok = c.interrogations[interrogationKey{BroadcastCommonAddr, typeID}]
+		if ok && apdu.ASDU.Cause == it.cause {
+			it.collect(commonAddr, apdu.Signals)
+		}
+	}
+}
+
+//collect 收集commonAddr上送的信息对象,全局召唤按公共地址分组,调用方需持有mu
+func (it *interrogation) collect(commonAddr uint16, signals []*Signal) {
+	if it.byAddr != nil {
+		it.byAddr[commonAddr] = append(it.byAddr[commonAddr], signals...)
+		return
+	}
+	it.signals = append(it.signals, signals...)
+}
+
+//failInterrogations 连接断开时结束所有正在进行的召唤
+func (c *Client) failInterrogations(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, it := range c.interrogations {
+		notify(it.done, err)
+	}
+}
@@ -0,0 +1,113 @@
+package iec104
+
+import "context"
+
+//OverflowPolicy 等待交给task的数据队列已满(task处理缓慢)时的处理策略
+type OverflowPolicy int
+
+const (
+	//OverflowDropOldest 丢弃队列中最早的帧后放入当前帧,读协程不等待
+	OverflowDropOldest OverflowPolicy = iota
+	//OverflowDropNewest 丢弃当前帧,读协程不等待
+	OverflowDropNewest
+	//OverflowBlock 读协程等待队列有空位,不丢弃数据。等待期间不读取后续帧,后续I帧得不到确认,
+	//task长时间阻塞时对端可能t1超时断开,task中调用SendCommand等需要等待响应的方法时会等到超时
+	OverflowBlock
+)
+
+//Pause 暂停将数据交给task,链路保持连接并继续确认收到的I帧。
+//暂停期间的数据按Config.PauseBuffer缓存,Resume后按接收顺序交给task
+func (c *Client) Pause() {
+	c.deliverMu.Lock()
+	defer c.deliverMu.Unlock()
+	c.paused = true
+}
+
+//Resume 恢复将数据交给task,先交付暂停期间缓存的数据
+func (c *Client) Resume() {
+	c.deliverMu.Lock()
+	defer c.deliverMu.Unlock()
+	c.paused = false
+	frames := c.pausedFrames
+	c.pausedFrames = nil
+	for _, apdu := range frames {
+		if c.enqueue(c.ctx, apdu) != nil {
+			return
+		}
+	}
+}
+
+//deliver 将数据交给task,暂停时缓存,超过Config.PauseBuffer时丢弃最早的数据
+func (c *Client) deliver(ctx context.Context, apdu *APDU) error {
+	c.deliverMu.Lock()
+	defer c.deliverMu.Unlock()
+	if c.paused {
+		if c.Config.PauseBuffer <= 0 {
+			return nil
+		}
+		if len(c.pausedFrames) >= c.Config.PauseBuffer {
+			c.Logger.Warnf("暂停期间缓存的数据超过%d帧,丢弃最早的数据", c.Config.PauseBuffer)
+			c.pausedFrames = c.pausedFrames[1:]
+		}
+		c.pausedFrames = append(c.pausedFrames, apdu)
+		return nil
+	}
+	return c.enqueue(ctx, apdu)
+}
+
+//enqueue 放入交给task的数据队列,队列已满时按Config.DataOverflow处理。调用方需持有deliverMu
+func (c *Client) enqueue(ctx context.Context, apdu *APDU) error {
+	select {
+	case c.dataChan <- apdu:
+		return nil
+	default:
+	}
+	switch c.Config.DataOverflow {
+	case OverflowDropOldest:
+		//只有持有deliverMu时才向队列写入,取出一帧后必有空位
+		select {
+		case old := <-c.dataChan:
+			c.dropFrame(old)
+		default:
+		}
+		c.dataChan <- apdu
+		return nil
+	case OverflowDropNewest:
+		c.dropFrame(apdu)
+		return nil
+	}
+	select {
+	case c.dataChan <- apdu:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//dropFrame 记录数据队列已满时丢弃的帧
+func (c *Client) dropFrame(apdu *APDU) {
+	c.Logger.Warnf("数据队列已满,丢弃数据,类型:%s,原因:%d", TypeIDName(apdu.ASDU.TypeID), apdu.ASDU.Cause)
+	c.mu.Lock()
+	c.droppedFrames++
+	c.mu.Unlock()
+}
+
+//Drain 取出尚未交给task的数据,包括数据通道和暂停期间缓存的数据,按接收顺序返回,没有时返回空切片。
+//用于Close之后保存最后收到的数据,运行期间调用会与task竞争数据
+func (c *Client) Drain() []*APDU {
+	c.deliverMu.Lock()
+	defer c.deliverMu.Unlock()
+	frames := make([]*APDU, 0, len(c.dataChan)+len(c.pausedFrames))
+loop:
+	for {
+		select {
+		case apdu := <-c.dataChan:
+			frames = append(frames, apdu)
+		default:
+			break loop
+		}
+	}
+	frames = append(frames, c.pausedFrames...)
+	c.pausedFrames = nil
+	return frames
+}
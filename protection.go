@@ -0,0 +1,61 @@
+package iec104
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+//ProtectionEvent 继电保护设备事件(类型38~40)的信息元素
+type ProtectionEvent struct {
+	//Event 类型38为事件状态ES(bit0~1);类型39为保护启动事件SPE;类型40为保护输出电路信息OCI
+	Event byte `json:"event"`
+	//Quality 类型38为单个事件品质(SEP的bit3~7);类型39、40为保护设备事件品质QDP
+	Quality byte `json:"quality"`
+	//Elapsed CP16Time2a,类型38为事件经过时间;类型39为继电器持续时间;类型40为继电器动作时间
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+//ElapsedInvalid 经过时间无效(EI)
+func (e *ProtectionEvent) ElapsedInvalid() bool {
+	return e.Quality&0x08 == 0x08
+}
+
+//ParseCP16Time2a 解析2个字节的时间CP16Time2a(0~59999毫秒),如继电器动作时间
+func ParseCP16Time2a(b []byte) (time.Duration, error) {
+	if len(b) != 2 {
+		return 0, fmt.Errorf("CP16Time2a[%X]长度非法", b)
+	}
+	return time.Duration(binary.LittleEndian.Uint16(b)) * time.Millisecond, nil
+}
+
+//parseProtectionEvent 解析继电保护设备事件的信息元素,事件存入s.Data
+func (asdu *ASDU) parseProtectionEvent(s *Signal, element []byte) {
+	e := new(ProtectionEvent)
+	if asdu.TypeID == MEpTd1 {
+		e.Event = element[0] & 0x03
+		e.Quality = element[0] &^ 0x07
+		element = element[1:]
+	} else {
+		e.Event = element[0]
+		e.Quality = element[1]
+		element = element[2:]
+	}
+	e.Elapsed, _ = ParseCP16Time2a(element[:2])
+	s.Value = float64(e.Event)
+	s.Quality = e.Quality
+	s.setTime(element[2:9])
+	s.Data = e
+}
+
+//handleProtectionEvents 将继电保护设备事件交给OnProtectionEvent
+func (c *Client) handleProtectionEvents(apdu *APDU) {
+	if c.Config.OnProtectionEvent == nil {
+		return
+	}
+	for _, s := range apdu.Signals {
+		if e, ok := s.Data.(*ProtectionEvent); ok {
+			c.Config.OnProtectionEvent(apdu.ASDU.PublicAddress, s, e)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package iec104
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestASDU_parseProtectionEvent(t *testing.T) {
+	tests := []struct {
+		name      string
+		asduBytes []byte
+		want      ProtectionEvent
+	}{
+		{"测试继电保护设备事件(MEpTd1)，type_id=38", []byte{0x26, 0x01, 0x03, 0x00, 0x01, 0x00, 0x01, 0x10, 0x00, 0x0a, 0xe8, 0x03, 0xD3, 0x42, 0x3B, 0x0E, 0x06, 0x0B, 0x13}, ProtectionEvent{Event: 2, Quality: 0x08, Elapsed: time.Second}},
+		{"测试继电保护设备成组启动事件(MEpTe1)，type_id=39", []byte{0x27, 0x01, 0x03, 0x00, 0x01, 0x00, 0x02, 0x10, 0x00, 0x03, 0x80, 0x64, 0x00, 0xD3, 0x42, 0x3B, 0x0E, 0x06, 0x0B, 0x13}, ProtectionEvent{Event: 0x03, Quality: 0x80, Elapsed: 100 * time.Millisecond}},
+		{"测试继电保护设备成组输出电路信息(MEpTf1)，type_id=40", []byte{0x28, 0x01, 0x03, 0x00, 0x01, 0x00, 0x03, 0x10, 0x00, 0x01, 0x00, 0x32, 0x00, 0xD3, 0x42, 0x3B, 0x0E, 0x06, 0x0B, 0x13}, ProtectionEvent{Event: 0x01, Elapsed: 50 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signals, err := new(ASDU).ParseASDU(tt.asduBytes)
+			if err != nil || len(signals) != 1 {
+				t.Fatalf("ASDU.ParseASDU() = %v, error = %v", signals, err)
+			}
+			e, ok := signals[0].Data.(*ProtectionEvent)
+			if !ok || !reflect.DeepEqual(*e, tt.want) {
+				t.Errorf("ASDU.ParseASDU() event = %v, want %v", signals[0].Data, tt.want)
+			}
+			if signals[0].Ts == 0 {
+				t.Error("ASDU.ParseASDU() 未解析时标")
+			}
+		})
+	}
+}
+
+func TestParseCP16Time2a(t *testing.T) {
+	tests := []struct {
+		name    string
+		b       []byte
+		want    time.Duration
+		wantErr bool
+	}{
+		{"测试继电器动作时间23毫秒", []byte{0x17, 0x00}, 23 * time.Millisecond, false},
+		{"测试最大值59999毫秒", []byte{0x5F, 0xEA}, 59999 * time.Millisecond, false},
+		{"测试长度非法", []byte{0x17}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCP16Time2a(tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCP16Time2a() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCP16Time2a() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
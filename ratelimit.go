@@ -0,0 +1,75 @@
+package iec104
+
+import (
+	"errors"
+	"time"
+)
+
+//ErrRateLimited 发送控制命令过于频繁,超过Config.CommandRate
+var ErrRateLimited = errors.New("发送控制命令过于频繁")
+
+//rateLimiter 控制命令的令牌桶,每个连接重新装满
+type rateLimiter struct {
+	tokens float64
+	last   time.Time
+}
+
+//commandBurst 令牌桶容量
+func (c *Client) commandBurst() float64 {
+	if c.Config.CommandBurst > 0 {
+		return float64(c.Config.CommandBurst)
+	}
+	return 1
+}
+
+//resetRateLimit 新连接时装满令牌桶,调用方需持有mu
+func (c *Client) resetRateLimit() {
+	c.limiter = rateLimiter{tokens: c.commandBurst(), last: c.clock().Now()}
+}
+
+//takeToken 取一个令牌,没有令牌时返回还需等待的时间。调用方需持有mu
+func (c *Client) takeToken() time.Duration {
+	now := c.clock().Now()
+	burst := c.commandBurst()
+	if c.limiter.last.IsZero() {
+		c.limiter.tokens = burst
+	} else if elapsed := now.Sub(c.limiter.last); elapsed > 0 {
+		c.limiter.tokens += elapsed.Seconds() * c.Config.CommandRate
+		if c.limiter.tokens > burst {
+			c.limiter.tokens = burst
+		}
+	}
+	c.limiter.last = now
+	if c.limiter.tokens >= 1 {
+		c.limiter.tokens--
+		return 0
+	}
+	return time.Duration((1 - c.limiter.tokens) / c.Config.CommandRate * float64(time.Second))
+}
+
+//waitCommandToken 发送控制命令前取令牌,未配置CommandRate时不限制。
+//没有令牌时按Config.RejectRateLimited返回ErrRateLimited,或最多等待timeout
+func (c *Client) waitCommandToken(timeout time.Duration) error {
+	if c.Config.CommandRate <= 0 {
+		return nil
+	}
+	deadline := c.clock().After(timeout)
+	for {
+		c.mu.Lock()
+		wait := c.takeToken()
+		c.mu.Unlock()
+		if wait == 0 {
+			return nil
+		}
+		if c.Config.RejectRateLimited {
+			return ErrRateLimited
+		}
+		select {
+		case <-c.clock().After(wait):
+		case <-deadline:
+			return ErrCommandTimeout
+		case <-c.ctx.Done():
+			return ErrNotConnected
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package iec104
+
+import (
+	"context"
+	"fmt"
+)
+
+//pendingRead 等待响应的读命令,同一信息对象的并发读共用一个读命令
+type pendingRead struct {
+	signal  *Signal
+	err     error
+	waiters int //等待该读命令的调用数,为0时从reads中删除
+	done    chan struct{}
+}
+
+//SendReadCommand 发送读命令(C_RD_NA_1),返回从站以传输原因5(请求)上送的该信息对象,
+//等待时间由ctx控制。同一信息对象已有读命令在等待时不重复发送,共用响应。
+//所有等待的调用都因ctx结束返回后丢弃该读命令,之后的读重新发送读命令
+func (c *Client) SendReadCommand(ctx context.Context, commonAddr uint16, ioa uint32) (*Signal, error) {
+	if !c.connected() {
+		return nil, ErrNotConnected
+	}
+	key := pointKey{commonAddr, ioa}
+	c.mu.Lock()
+	r, ok := c.reads[key]
+	if !ok {
+		r = &pendingRead{done: make(chan struct{})}
+		c.reads[key] = r
+	}
+	r.waiters++
+	c.mu.Unlock()
+	if !ok {
+		data, err := c.sendIFrame(encodeCommandASDU(CRdNa1, c.cause(CauseRequest), c.Config.Originator, commonAddr, ioa, nil))
+		if err != nil {
+			c.finishRead(key, r, nil, err)
+			return nil, err
+		}
+		c.Logger.Debugf("发送读命令,公共地址:%d,信息对象地址:%d: [% X]", commonAddr, ioa, data)
+	}
+	select {
+	case <-r.done:
+		return r.signal, r.err
+	case <-ctx.Done():
+		c.cancelRead(key, r)
+		return nil, ctx.Err()
+	}
+}
+
+//cancelRead 一个等待的调用放弃读命令,没有其他等待的调用时从reads中删除
+func (c *Client) cancelRead(key pointKey, r *pendingRead) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r.waiters--
+	if r.waiters == 0 && c.reads[key] == r {
+		delete(c.reads, key)
+	}
+}
+
+//finishRead 结束读命令,r已结束时忽略
+func (c *Client) finishRead(key pointKey, r *pendingRead, s *Signal, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reads[key] != r {
+		return
+	}
+	delete(c.reads, key)
+	r.signal = s
+	r.err = err
+	close(r.done)
+}
+
+//handleReadResponse 将传输原因为5(请求)的信息对象交给等待的读命令
+func (c *Client) handleReadResponse(apdu *APDU) {
+	if apdu.ASDU.Cause != CauseRequest {
+		return
+	}
+	for _, s := range apdu.Signals {
+		key := pointKey{apdu.ASDU.PublicAddress, s.Address}
+		c.mu.Lock()
+		r, ok := c.reads[key]
+		c.mu.Unlock()
+		if ok {
+			c.finishRead(key, r, s, nil)
+		}
+	}
+}
+
+//handleReadReject 处理读命令的否定响应,如未知的信息对象地址(传输原因47),其他响应忽略
+func (c *Client) handleReadReject(apdu *APDU) {
+	if !apdu.ASDU.Negative && apdu.ASDU.Cause < CauseUnknownTypeID {
+		return
+	}
+	for _, s := range apdu.Signals {
+		key := pointKey{apdu.ASDU.PublicAddress, s.Address}
+		c.mu.Lock()
+		r, ok := c.reads[key]
+		c.mu.Unlock()
+		if ok {
+			c.finishRead(key, r, nil, fmt.Errorf("读命令被拒绝,传输原因:%d", apdu.ASDU.Cause))
+		}
+	}
+}
+
+//failReads 连接断开时结束所有等待的读命令
+func (c *Client) failReads(err error) {
+	c.mu.Lock()
+	reads := c.reads
+	c.reads = make(map[pointKey]*pendingRead)
+	c.mu.Unlock()
+	for _, r := range reads {
+		r.err = err
+		close(r.done)
+	}
+}
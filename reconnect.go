@@ -0,0 +1,46 @@
+package iec104
+
+import "time"
+
+//ReconnectPolicy 重连策略,连接服务器失败后决定是否继续重试及等待时间
+type ReconnectPolicy interface {
+	//ShouldReconnect attempt为连续失败的次数,从1开始,连接成功后重新计数,lastErr为最近一次连接异常。
+	//retry为false时停止重连并关闭客户端,RunContext返回lastErr
+	ShouldReconnect(attempt int, lastErr error) (retry bool, wait time.Duration)
+}
+
+//ExponentialBackoff 指数退避重连策略,第n次失败后等待Initial*2^(n-1),不超过Max
+type ExponentialBackoff struct {
+	Initial     time.Duration //首次等待时间,为0时使用1秒
+	Max         time.Duration //最长等待时间,为0时使用1分钟
+	MaxAttempts int           //连续失败超过该次数后停止重连,为0时不限制
+}
+
+//ShouldReconnect 实现ReconnectPolicy
+func (b ExponentialBackoff) ShouldReconnect(attempt int, lastErr error) (bool, time.Duration) {
+	if b.MaxAttempts > 0 && attempt > b.MaxAttempts {
+		return false, 0
+	}
+	wait, max := b.Initial, b.Max
+	if wait <= 0 {
+		wait = time.Second
+	}
+	if max <= 0 {
+		max = time.Minute
+	}
+	for i := 1; i < attempt && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		wait = max
+	}
+	return true, wait
+}
+
+//reconnectWait 按Config.ReconnectPolicy决定连接失败后是否重试,未配置时每隔5秒重试
+func (c *Client) reconnectWait(attempt int, lastErr error) (bool, time.Duration) {
+	if c.Config.ReconnectPolicy == nil {
+		return true, dialTimeout
+	}
+	return c.Config.ReconnectPolicy.ShouldReconnect(attempt, lastErr)
+}
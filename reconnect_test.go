@@ -0,0 +1,29 @@
+package iec104
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff_ShouldReconnect(t *testing.T) {
+	tests := []struct {
+		name      string
+		b         ExponentialBackoff
+		attempt   int
+		wantRetry bool
+		wantWait  time.Duration
+	}{
+		{"测试首次失败", ExponentialBackoff{}, 1, true, time.Second},
+		{"测试第3次失败", ExponentialBackoff{Initial: 2 * time.Second}, 3, true, 8 * time.Second},
+		{"测试不超过最长等待时间", ExponentialBackoff{Max: 10 * time.Second}, 10, true, 10 * time.Second},
+		{"测试超过最大次数", ExponentialBackoff{MaxAttempts: 3}, 4, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retry, wait := tt.b.ShouldReconnect(tt.attempt, nil)
+			if retry != tt.wantRetry || wait != tt.wantWait {
+				t.Errorf("ExponentialBackoff.ShouldReconnect() = %v, %v, want %v, %v", retry, wait, tt.wantRetry, tt.wantWait)
+			}
+		})
+	}
+}
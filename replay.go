@@ -0,0 +1,24 @@
+package iec104
+
+import (
+	"fmt"
+	"io"
+)
+
+//ReplayFrames 从r中依次读取抓包保存的APDU帧并解析,每解析一帧调用一次handler,不需要网络连接
+func ReplayFrames(r io.Reader, handler func(*APDU)) error {
+	for i := 1; ; i++ {
+		frame, err := ReadFrame(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取第%d帧异常: %v", i, err)
+		}
+		apdu, err := DecodeAPDU(frame)
+		if err != nil {
+			return fmt.Errorf("解析第%d帧异常: %v", i, err)
+		}
+		handler(apdu)
+	}
+}
@@ -0,0 +1,34 @@
+package iec104
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReplayFrames(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		want    int
+		wantErr bool
+	}{
+		{"测试回放U帧、S帧和I帧", []byte{0x68, 0x04, 0x0B, 0x00, 0x00, 0x00,
+			0x68, 0x04, 0x01, 0x00, 0x02, 0x00,
+			0x68, 0x0E, 0x00, 0x00, 0x02, 0x00, 0x64, 0x01, 0x07, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x14}, 3, false},
+		{"测试空输入", []byte{}, 0, false},
+		{"测试起始符非法", []byte{0x67, 0x04, 0x0B, 0x00, 0x00, 0x00}, 0, true},
+		{"测试帧不完整", []byte{0x68, 0x04, 0x0B, 0x00, 0x00, 0x00, 0x68, 0x0E, 0x00, 0x00}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := 0
+			err := ReplayFrames(bytes.NewReader(tt.input), func(*APDU) { got++ })
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReplayFrames() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReplayFrames() handled %d frames, want %d", got, tt.want)
+			}
+		})
+	}
+}
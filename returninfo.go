@@ -0,0 +1,126 @@
+package iec104
+
+import "fmt"
+
+//pendingReturn 等待返送信息的命令
+type pendingReturn struct {
+	signal *Signal
+	err    error
+	done   chan struct{}
+}
+
+//SendCommandWithReturn 发送控制命令,执行确认后继续等待从站以传输原因11(远方命令引起的返送信息)上送的信息对象returnIOA,
+//返回命令执行后的状态。returnIOA为0时与命令的信息对象地址相同,返送信息可在激活终止之前或之后到达,等待时间与确认超时相同。
+//多个命令等待同一信息对象的返送信息时依次发送,前一个命令收到返送信息或超时后才发送下一个,保证返送信息与命令一一对应
+func (c *Client) SendCommandWithReturn(cmd Command, returnIOA uint32) (*Signal, error) {
+	if returnIOA == 0 {
+		returnIOA = cmd.IOA
+	}
+	key := pointKey{cmd.CommonAddr, returnIOA}
+	r := &pendingReturn{done: make(chan struct{})}
+	if err := c.queueReturn(key, r); err != nil {
+		return nil, err
+	}
+	defer c.finishReturn(key, r, nil, nil)
+	if err := c.SendCommand(cmd); err != nil {
+		return nil, err
+	}
+	select {
+	case <-r.done:
+		return r.signal, r.err
+	case <-c.clock().After(c.timeoutFor(cmd)):
+		return nil, ErrReturnTimeout
+	}
+}
+
+//SendDoubleCommandWithReturn 发送双点命令并等待返送信息,返回的信息对象Value为执行后的双点遥信状态DPI
+func (c *Client) SendDoubleCommandWithReturn(commonAddr uint16, ioa uint32, on bool, sbo bool, returnIOA uint32) (*Signal, error) {
+	return c.SendCommandWithReturn(doubleCommand(commonAddr, ioa, on, sbo), returnIOA)
+}
+
+//SendRegulatingStepCommand 发送调节步命令(C_RC_NA_1),higher为true时升一步(RCS=2),否则降一步(RCS=1)
+func (c *Client) SendRegulatingStepCommand(commonAddr uint16, ioa uint32, higher bool, sbo bool) error {
+	return c.SendCommand(regulatingStepCommand(commonAddr, ioa, higher, sbo))
+}
+
+//SendRegulatingStepCommandWithReturn 发送调节步命令并等待步位置信息(类型5或32)的返送信息,返回执行后的档位
+func (c *Client) SendRegulatingStepCommandWithReturn(commonAddr uint16, ioa uint32, higher bool, sbo bool, returnIOA uint32) (StepPosition, error) {
+	s, err := c.SendCommandWithReturn(regulatingStepCommand(commonAddr, ioa, higher, sbo), returnIOA)
+	if err != nil {
+		return StepPosition{}, err
+	}
+	p, ok := s.Data.(*StepPosition)
+	if !ok {
+		return StepPosition{}, fmt.Errorf("返送信息类型[%d]不是步位置信息", s.TypeID)
+	}
+	return *p, nil
+}
+
+//regulatingStepCommand 调节步命令,无附加定义
+func regulatingStepCommand(commonAddr uint16, ioa uint32, higher bool, sbo bool) Command {
+	rco := byte(0x01)
+	if higher {
+		rco = 0x02
+	}
+	return Command{TypeID: CRcNa1, CommonAddr: commonAddr, IOA: ioa, Value: []byte{rco}, Select: sbo}
+}
+
+//queueReturn 等待同一信息对象的前一个命令结束后登记r。前一个命令最长等待确认超时时间后结束,不需要单独计时
+func (c *Client) queueReturn(key pointKey, r *pendingReturn) error {
+	for {
+		c.mu.Lock()
+		prev, ok := c.returns[key]
+		if !ok {
+			c.returns[key] = r
+			c.mu.Unlock()
+			return nil
+		}
+		c.mu.Unlock()
+		select {
+		case <-prev.done:
+		case <-c.ctx.Done():
+			return ErrNotConnected
+		}
+	}
+}
+
+//finishReturn 结束等待返送信息,r已结束时忽略
+func (c *Client) finishReturn(key pointKey, r *pendingReturn, s *Signal, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.returns[key] != r {
+		return
+	}
+	delete(c.returns, key)
+	r.signal = s
+	r.err = err
+	close(r.done)
+}
+
+//handleReturnInfo 将传输原因为11(远方命令引起的返送信息)的信息对象交给等待的命令
+func (c *Client) handleReturnInfo(apdu *APDU) {
+	if apdu.ASDU.Cause != CauseReturnRemote {
+		return
+	}
+	for _, s := range apdu.Signals {
+		key := pointKey{apdu.ASDU.PublicAddress, s.Address}
+		c.mu.Lock()
+		r, ok := c.returns[key]
+		c.mu.Unlock()
+		if ok {
+			c.finishReturn(key, r, s, nil)
+		}
+	}
+}
+
+//failReturns 连接断开时结束所有等待返送信息的命令
+func (c *Client) failReturns(err error) {
+	c.mu.Lock()
+	returns := c.returns
+	c.returns = make(map[pointKey]*pendingReturn)
+	c.mu.Unlock()
+	for _, r := range returns {
+		r.err = err
+		close(r.done)
+	}
+}
@@ -0,0 +1,109 @@
+package iec104
+
+import "time"
+
+//I帧的发送优先级,数值大的先发送。S帧和U帧不参与调度,总是先于排队的I帧发送
+const (
+	PriorityInterrogation = 0 //总召唤、计数量召唤、读命令和文件传输
+	PriorityNormal        = 1 //时钟同步等其他I帧
+	PriorityCommand       = 2 //控制命令和复位进程命令
+)
+
+//DefaultSendPriority 默认的发送调度策略:控制命令最先,召唤最后,保证操作员的命令不会排在大量召唤之后
+func DefaultSendPriority(typeID byte) int {
+	switch typeID {
+	case CScNa1, CDcNa1, CRcNa1, CSeNb1, CSeNc1, CScTa1, CBoTa1, CRpNa1:
+		return PriorityCommand
+	case CIcNa1, CCiNa1, CRdNa1, FScNa1:
+		return PriorityInterrogation
+	}
+	return PriorityNormal
+}
+
+//sendWaiter 等待发送的I帧
+type sendWaiter struct {
+	priority int
+	ready    chan struct{} //轮到发送时关闭
+}
+
+//sendPriority 按Config.SendPriority计算I帧的优先级
+func (c *Client) sendPriority(typeID byte) int {
+	if c.Config.SendPriority != nil {
+		return c.Config.SendPriority(typeID)
+	}
+	return DefaultSendPriority(typeID)
+}
+
+//acquireSend 等待轮到发送I帧:同一时间只有一个I帧填充序号并进入发送队列,多个I帧等待时优先级高的先发送,
+//相同优先级按调用顺序。已发送未确认的I帧达到k时等待对端确认。连接断开时返回ErrNotConnected,
+//timeout大于0时最多等待timeout,超时返回ErrCommandTimeout
+func (c *Client) acquireSend(priority int, timeout time.Duration) error {
+	c.mu.Lock()
+	connCtx := c.connCtx
+	if connCtx == nil || connCtx.Err() != nil {
+		c.mu.Unlock()
+		return ErrNotConnected
+	}
+	w := &sendWaiter{priority: priority, ready: make(chan struct{})}
+	i := len(c.sendWaiters)
+	for i > 0 && c.sendWaiters[i-1].priority < priority {
+		i--
+	}
+	c.sendWaiters = append(c.sendWaiters, nil)
+	copy(c.sendWaiters[i+1:], c.sendWaiters[i:])
+	c.sendWaiters[i] = w
+	c.grantSend()
+	c.mu.Unlock()
+	select {
+	case <-w.ready:
+		return nil
+	default:
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = c.clock().After(timeout)
+	}
+	err := ErrNotConnected
+	select {
+	case <-w.ready:
+		return nil
+	case <-connCtx.Done():
+	case <-expired:
+		err = ErrCommandTimeout
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-w.ready:
+		//断开或超时的同时轮到发送,交给下一个
+		c.sendBusy = false
+		c.grantSend()
+	default:
+		for i, other := range c.sendWaiters {
+			if other == w {
+				c.sendWaiters = append(c.sendWaiters[:i], c.sendWaiters[i+1:]...)
+				break
+			}
+		}
+	}
+	return err
+}
+
+//releaseSend I帧已进入发送队列,轮到下一个等待的I帧
+func (c *Client) releaseSend() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sendBusy = false
+	c.grantSend()
+}
+
+//grantSend 没有正在发送的I帧且发送窗口未满时,通知优先级最高的等待者发送,调用方需持有mu
+func (c *Client) grantSend() {
+	if c.sendBusy || len(c.sendWaiters) == 0 || c.outstanding() >= c.sendWindow() {
+		return
+	}
+	w := c.sendWaiters[0]
+	c.sendWaiters = c.sendWaiters[1:]
+	c.sendBusy = true
+	close(w.ready)
+}
@@ -0,0 +1,59 @@
+package iec104
+
+//SeqNumbers 需要跨进程保存的序号状态
+type SeqNumbers struct {
+	SendSeq  uint16 `json:"send_seq"`  //下一个发送的I帧的发送序号ssn
+	RecvSeq  uint16 `json:"recv_seq"`  //接收序号rsn,即下一个期望收到的I帧的发送序号
+	PeerAck  uint16 `json:"peer_ack"`  //对端确认的接收序号,SendSeq与之差值为已发送未确认的I帧数量
+	AckedSeq uint16 `json:"acked_seq"` //最近一次向对端确认的接收序号,RecvSeq与之差值为已接收未确认的I帧数量
+}
+
+//SeqStore 序号状态的持久化,用于进程重启后沿用原有会话的序号。
+//
+//IEC 104规定每次建立TCP连接时收发序号从0开始,只有对端也保留会话状态时(通常需要网关保持与对端的TCP连接不断开)
+//恢复序号才有意义,否则对端会因序号不一致断开连接。进程内断线重连、StartDT时仍然清零,
+//已发送未确认的I帧不会重发,只恢复计数。
+//
+//Save在每次序号变化时调用,调用时持有客户端内部锁,应尽快返回且不能调用Client的方法
+type SeqStore interface {
+	//Load 读取保存的序号状态,ok为false时表示没有保存的状态,序号从0开始
+	Load() (seq SeqNumbers, ok bool, err error)
+	//Save 保存当前的序号状态
+	Save(seq SeqNumbers) error
+}
+
+//restoreSeq 从Config.SeqStore恢复序号,没有保存的状态或读取失败时清零,调用方需持有mu
+func (c *Client) restoreSeq() {
+	seq, ok, err := c.Config.SeqStore.Load()
+	if err != nil {
+		c.Logger.Warnf("读取保存的序号状态异常,序号从0开始: %v", err)
+	}
+	if err != nil || !ok {
+		c.resetSeq()
+		return
+	}
+	c.ssn = int16(seq.SendSeq & 0x7fff)
+	c.rsn = int16(seq.RecvSeq & 0x7fff)
+	c.peerAck = int16(seq.PeerAck & 0x7fff)
+	c.ackedRsn = int16(seq.AckedSeq & 0x7fff)
+	c.unacked = (int(c.rsn) - int(c.ackedRsn) + 1<<15) % (1 << 15)
+	c.ackTimer = false
+	c.unackedSent = nil
+	c.Logger.Infof("恢复保存的序号状态,发送序号:%d,接收序号:%d,对端确认序号:%d", c.ssn, c.rsn, c.peerAck)
+}
+
+//saveSeq 序号变化后保存到Config.SeqStore,调用方需持有mu
+func (c *Client) saveSeq() {
+	if c.Config.SeqStore == nil {
+		return
+	}
+	seq := SeqNumbers{
+		SendSeq:  uint16(c.ssn),
+		RecvSeq:  uint16(c.rsn),
+		PeerAck:  uint16(c.peerAck),
+		AckedSeq: uint16(c.ackedRsn),
+	}
+	if err := c.Config.SeqStore.Save(seq); err != nil {
+		c.Logger.Warnf("保存序号状态异常: %v", err)
+	}
+}
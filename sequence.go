@@ -0,0 +1,41 @@
+package iec104
+
+//SeqErrorKind 序号异常的类型
+type SeqErrorKind int
+
+const (
+	//SeqErrorSend I帧的发送序号不等于期望的接收序号,对端重发或丢失了I帧
+	SeqErrorSend SeqErrorKind = iota
+	//SeqErrorAck 对端确认的接收序号超出已发送的I帧,确认了不存在的I帧
+	SeqErrorAck
+)
+
+//SeqErrorDetail 违反序号规则的帧
+type SeqErrorDetail struct {
+	Kind     SeqErrorKind
+	Expected uint16 //SeqErrorSend为期望的发送序号,SeqErrorAck为已发送的最大序号加1
+	Received uint16 //帧中的发送序号或接收序号
+	Frame    []byte //完整帧
+}
+
+//checkSendSeq 校验I帧的发送序号是否等于当前接收序号
+func (c *Client) checkSendSeq(send int16, apdu *APDU) {
+	c.mu.Lock()
+	expected := c.rsn
+	c.mu.Unlock()
+	if send != expected {
+		c.seqError(SeqErrorDetail{Kind: SeqErrorSend, Expected: uint16(expected), Received: uint16(send), Frame: apdu.Raw})
+	}
+}
+
+//seqError 记录序号异常并调用OnSequenceError,之后继续按收到的帧处理
+func (c *Client) seqError(detail SeqErrorDetail) {
+	if detail.Kind == SeqErrorSend {
+		c.Logger.Warnf("I帧发送序号异常,期望:%d,实际:%d: [% X]", detail.Expected, detail.Received, detail.Frame)
+	} else {
+		c.Logger.Warnf("确认了未发送的I帧,已发送:%d,确认:%d: [% X]", detail.Expected, detail.Received, detail.Frame)
+	}
+	if c.Config.OnSequenceError != nil {
+		c.Config.OnSequenceError(detail)
+	}
+}
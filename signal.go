@@ -1,10 +1,163 @@
 package iec104
 
+import (
+	"encoding/binary"
+	"math"
+)
+
 //Signal 104信号
 type Signal struct {
-	TypeID  uint    `json:"type_id"` //类型id，1:单点遥信，9:单点遥测
-	Address uint32  `json:"address"` //地址
-	Value   float64 `json:"value"`   //值
-	Quality byte    `json:"quality"` //品质描述
-	Ts      float64 `json:"ts"`      //毫秒时间戳
+	TypeID     uint        `json:"type_id"`               //类型id，1:单点遥信，9:单点遥测
+	Address    uint32      `json:"address"`               //地址
+	Value      float64     `json:"value"`                 //值
+	Quality    byte        `json:"quality"`               //品质描述
+	Ts         float64     `json:"ts"`                    //毫秒时间戳
+	Cause      Cause       `json:"cause"`                 //传输原因
+	Data       interface{} `json:"data,omitempty"`        //私有类型自定义解析的结构化值
+	SummerTime bool        `json:"summer_time,omitempty"` //时标的夏令时标志SU
+}
+
+//setTime 解析7个字节时标,设置Ts和SummerTime
+func (s *Signal) setTime(b []byte) {
+	t, summer := ParseCP56Time2a(b)
+	s.Ts = unixSeconds(t)
+	s.SummerTime = summer
+}
+
+//IsCyclic 是否为周期/循环上送(传输原因1)
+func (s *Signal) IsCyclic() bool {
+	return s.Cause == CausePeriodic
+}
+
+//IsBackground 是否为背景扫描(传输原因2)
+func (s *Signal) IsBackground() bool {
+	return s.Cause == CauseBackground
+}
+
+//IsSpontaneous 是否为突发上送,如越过死区(传输原因3)
+func (s *Signal) IsSpontaneous() bool {
+	return s.Cause == CauseSpontaneous
+}
+
+//Overflow 测量值溢出(品质描述词QDS的OV位),如仪表已达满量程
+func (s *Signal) Overflow() bool {
+	return s.QDS().Overflow
+}
+
+//QDS 测量值(类型9、11、13、34、35、36)的品质描述词
+type QDS struct {
+	Overflow    bool `json:"overflow"`    //溢出OV,bit0
+	Blocked     bool `json:"blocked"`     //被闭锁BL,bit4
+	Substituted bool `json:"substituted"` //被取代SB,bit5
+	NotTopical  bool `json:"not_topical"` //非当前值NT,bit6
+	Invalid     bool `json:"invalid"`     //无效IV,bit7
+}
+
+//ParseQDS 解析品质描述词QDS
+func ParseQDS(b byte) QDS {
+	return QDS{
+		Overflow:    b&0x01 == 0x01,
+		Blocked:     b&0x10 == 0x10,
+		Substituted: b&0x20 == 0x20,
+		NotTopical:  b&0x40 == 0x40,
+		Invalid:     b&0x80 == 0x80,
+	}
+}
+
+//QDS 测量值的品质描述词,Quality中保存原始字节
+func (s *Signal) QDS() QDS {
+	return ParseQDS(s.Quality)
+}
+
+//setMeasuredValue 解析测量值和品质描述词,归一化值不做符号扩展,标度化值为有符号数
+func (s *Signal) setMeasuredValue(typeID byte, element []byte) {
+	switch typeID {
+	case MMeNa1, MMeTd1:
+		s.Value = float64(binary.LittleEndian.Uint16(element[:2]))
+	case MMeNb1, MMeTe1:
+		s.Value = float64(int16(binary.LittleEndian.Uint16(element[:2])))
+	case MMeNc1, MMeTf1:
+		s.Value = float64(math.Float32frombits(binary.LittleEndian.Uint32(element[:4])))
+	}
+	s.Quality = element[len(element)-1]
+}
+
+//BCR 累计量的顺序记法
+type BCR struct {
+	Sequence byte `json:"sequence"` //顺序号SQ,bit0~4
+	Carry    bool `json:"carry"`    //进位CY,上次读数后计数器溢出
+	Adjusted bool `json:"adjusted"` //计数量被调整CA,上次读数后计数器被复位或设定
+	Invalid  bool `json:"invalid"`  //无效IV
+}
+
+//ParseBCR 解析累计量的顺序记法字节
+func ParseBCR(b byte) BCR {
+	return BCR{
+		Sequence: b & 0x1f,
+		Carry:    b&0x20 == 0x20,
+		Adjusted: b&0x40 == 0x40,
+		Invalid:  b&0x80 == 0x80,
+	}
+}
+
+//Counter 累计量(类型15、37)的顺序记法,Quality中保存原始字节
+func (s *Signal) Counter() BCR {
+	return ParseBCR(s.Quality)
+}
+
+//初始化原因COI的低7位
+const (
+	CoiLocalPowerOn     byte = 0 //当地电源合上
+	CoiLocalManualReset byte = 1 //当地手动复位
+	CoiRemoteReset      byte = 2 //远方复位
+)
+
+//EndOfInit 初始化结束(类型70)的初始化原因
+type EndOfInit struct {
+	Cause       byte `json:"cause"`        //初始化原因,COI的bit0~6
+	LocalChange bool `json:"local_change"` //COI的bit7,当地参数改变后的初始化
+}
+
+//ParseCOI 解析初始化原因COI
+func ParseCOI(b byte) (cause byte, localChange bool) {
+	return b & 0x7f, b&0x80 == 0x80
+}
+
+//StepPosition 步位置信息(类型5、32)的带瞬变状态指示的值VTI
+type StepPosition struct {
+	Value     int8 `json:"value"`     //步位置,bit0~6为有符号数,范围-64~63
+	Transient bool `json:"transient"` //瞬变状态T,bit7,设备正在调节中
+}
+
+//ParseVTI 解析带瞬变状态指示的值VTI,只对低7位做符号扩展,最高位为瞬变状态
+func ParseVTI(b byte) StepPosition {
+	return StepPosition{
+		Value:     int8(b<<1) >> 1,
+		Transient: b&0x80 == 0x80,
+	}
+}
+
+//setStepPosition 解析VTI,设置Value和Data
+func (s *Signal) setStepPosition(vti byte) {
+	p := ParseVTI(vti)
+	s.Value = float64(p.Value)
+	s.Data = &p
+}
+
+//PackedSinglePoint 带变位检出的成组单点信息(类型20)的状态和变位检出SCD
+type PackedSinglePoint struct {
+	Status  [16]bool `json:"status"`  //第1~16个单点的状态ST
+	Changed [16]bool `json:"changed"` //第1~16个单点自上次上送后是否变位CD
+}
+
+//ParsePackedSinglePoint 解析4个字节的状态和变位检出,前2个字节为状态,后2个字节为变位检出,bit0对应第1个单点
+func ParsePackedSinglePoint(b []byte) *PackedSinglePoint {
+	p := new(PackedSinglePoint)
+	st := binary.LittleEndian.Uint16(b[:2])
+	cd := binary.LittleEndian.Uint16(b[2:4])
+	for i := 0; i < 16; i++ {
+		p.Status[i] = st&(1<<uint(i)) != 0
+		p.Changed[i] = cd&(1<<uint(i)) != 0
+	}
+	return p
 }
@@ -0,0 +1,138 @@
+package iec104
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBCR(t *testing.T) {
+	tests := []struct {
+		name string
+		b    byte
+		want BCR
+	}{
+		{"测试正常计数", 0x03, BCR{Sequence: 3}},
+		{"测试计数器溢出", 0x25, BCR{Sequence: 5, Carry: true}},
+		{"测试计数器被调整", 0x5f, BCR{Sequence: 31, Adjusted: true}},
+		{"测试计数无效", 0x83, BCR{Sequence: 3, Invalid: true}},
+		{"测试全部置位", 0xff, BCR{Sequence: 31, Carry: true, Adjusted: true, Invalid: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseBCR(tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseBCR() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignal_CausePredicates(t *testing.T) {
+	tests := []struct {
+		name            string
+		cause           Cause
+		wantCyclic      bool
+		wantBackground  bool
+		wantSpontaneous bool
+	}{
+		{"测试周期上送", CausePeriodic, true, false, false},
+		{"测试背景扫描", CauseBackground, false, true, false},
+		{"测试突发上送", CauseSpontaneous, false, false, true},
+		{"测试响应站召唤", CauseInterrogatedByStation, false, false, false},
+		{"测试被请求", CauseRequest, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Signal{Cause: tt.cause}
+			if got := s.IsCyclic(); got != tt.wantCyclic {
+				t.Errorf("Signal.IsCyclic() = %v, want %v", got, tt.wantCyclic)
+			}
+			if got := s.IsBackground(); got != tt.wantBackground {
+				t.Errorf("Signal.IsBackground() = %v, want %v", got, tt.wantBackground)
+			}
+			if got := s.IsSpontaneous(); got != tt.wantSpontaneous {
+				t.Errorf("Signal.IsSpontaneous() = %v, want %v", got, tt.wantSpontaneous)
+			}
+		})
+	}
+}
+
+func TestParseQDS(t *testing.T) {
+	tests := []struct {
+		name string
+		b    byte
+		want QDS
+	}{
+		{"测试品质正常", 0x00, QDS{}},
+		{"测试溢出", 0x01, QDS{Overflow: true}},
+		{"测试被闭锁", 0x10, QDS{Blocked: true}},
+		{"测试被取代", 0x20, QDS{Substituted: true}},
+		{"测试非当前值", 0x40, QDS{NotTopical: true}},
+		{"测试无效", 0x80, QDS{Invalid: true}},
+		{"测试保留位不影响品质", 0x0e, QDS{}},
+		{"测试全部置位", 0xff, QDS{Overflow: true, Blocked: true, Substituted: true, NotTopical: true, Invalid: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseQDS(tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseQDS() = %v, want %v", got, tt.want)
+			}
+			s := &Signal{Quality: tt.b}
+			if got := s.Overflow(); got != tt.want.Overflow {
+				t.Errorf("Signal.Overflow() = %v, want %v", got, tt.want.Overflow)
+			}
+		})
+	}
+}
+
+func TestParseCOI(t *testing.T) {
+	tests := []struct {
+		name            string
+		b               byte
+		wantCause       byte
+		wantLocalChange bool
+	}{
+		{"测试当地电源合上", 0x00, CoiLocalPowerOn, false},
+		{"测试远方复位", 0x02, CoiRemoteReset, false},
+		{"测试当地参数改变后手动复位", 0x81, CoiLocalManualReset, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cause, localChange := ParseCOI(tt.b)
+			if cause != tt.wantCause || localChange != tt.wantLocalChange {
+				t.Errorf("ParseCOI() = %v, %v, want %v, %v", cause, localChange, tt.wantCause, tt.wantLocalChange)
+			}
+		})
+	}
+}
+
+func TestParsePackedSinglePoint(t *testing.T) {
+	want := new(PackedSinglePoint)
+	want.Status[0], want.Status[15] = true, true
+	want.Changed[15] = true
+	if got := ParsePackedSinglePoint([]byte{0x01, 0x80, 0x00, 0x80}); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePackedSinglePoint() = %v, want %v", got, want)
+	}
+}
+
+func TestParseVTI(t *testing.T) {
+	tests := []struct {
+		name string
+		b    byte
+		want StepPosition
+	}{
+		{"测试正位置", 0x05, StepPosition{Value: 5}},
+		{"测试最大位置", 0x3f, StepPosition{Value: 63}},
+		{"测试最小位置", 0x40, StepPosition{Value: -64}},
+		{"测试负位置", 0x7f, StepPosition{Value: -1}},
+		//直接转换为int8时为127
+		{"测试瞬变状态的负位置", 0xff, StepPosition{Value: -1, Transient: true}},
+		{"测试瞬变状态的正位置", 0x83, StepPosition{Value: 3, Transient: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseVTI(tt.b); got != tt.want {
+				t.Errorf("ParseVTI() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
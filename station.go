@@ -0,0 +1,77 @@
+package iec104
+
+import (
+	"sort"
+	"time"
+)
+
+//StationStatus 公共地址的自动总召唤状态
+type StationStatus struct {
+	CommonAddr   uint16    `json:"common_addr"`
+	LastRequest  time.Time `json:"last_request"`  //最近一次发送总召唤的时间
+	LastComplete time.Time `json:"last_complete"` //最近一次收到总召唤结束的时间,未收到时为零值
+	InProgress   bool      `json:"in_progress"`   //已发送总召唤,尚未收到召唤结束
+}
+
+//interrogationAddrs 自动召唤的公共地址
+func (c *Client) interrogationAddrs() []uint16 {
+	if len(c.Config.InterrogationAddrs) > 0 {
+		return c.Config.InterrogationAddrs
+	}
+	return []uint16{c.commonAddr()}
+}
+
+//interrogationSpacing 自动召唤相邻两个公共地址的间隔
+func (c *Client) interrogationSpacing() time.Duration {
+	if c.Config.InterrogationSpacing > 0 {
+		return c.Config.InterrogationSpacing
+	}
+	return interrogationSpacing
+}
+
+//InterrogationStatus 返回各公共地址的自动总召唤状态,按公共地址排序
+func (c *Client) InterrogationStatus() []StationStatus {
+	c.mu.Lock()
+	statuses := make([]StationStatus, 0, len(c.stations))
+	for _, s := range c.stations {
+		statuses = append(statuses, *s)
+	}
+	c.mu.Unlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].CommonAddr < statuses[j].CommonAddr })
+	return statuses
+}
+
+//station 获取公共地址的召唤状态,不存在时创建。调用方需持有mu
+func (c *Client) station(commonAddr uint16) *StationStatus {
+	s, ok := c.stations[commonAddr]
+	if !ok {
+		s = &StationStatus{CommonAddr: commonAddr}
+		c.stations[commonAddr] = s
+	}
+	return s
+}
+
+//stationCallSent 记录发送总召唤
+func (c *Client) stationCallSent(commonAddr uint16) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.station(commonAddr)
+	s.LastRequest = c.clock().Now()
+	s.InProgress = true
+}
+
+//stationCallFailed 总召唤发送失败,不再处于召唤中
+func (c *Client) stationCallFailed(commonAddr uint16) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.station(commonAddr).InProgress = false
+}
+
+//stationCallComplete 记录收到总召唤结束
+func (c *Client) stationCallComplete(commonAddr uint16) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.station(commonAddr)
+	s.LastComplete = c.clock().Now()
+	s.InProgress = false
+}
@@ -0,0 +1,164 @@
+package iec104
+
+import (
+	"bytes"
+	"net"
+	"sync"
+)
+
+//TestServer 模拟从站的测试服务器,用于验证主站对协议异常的处理(重新同步、序号校验和超时)。
+//自动回复启动确认帧和停止确认帧,收到的其余帧按接收顺序写入Frames,同一时间只处理最近建立的连接
+type TestServer struct {
+	ln     net.Listener
+	mu     sync.Mutex
+	conn   net.Conn
+	ssn    int16
+	rsn    int16
+	frames chan []byte
+	//autoAck 每收到一个I帧回复S帧,skipAck为true时跳过下一次
+	autoAck bool
+	skipAck bool
+	//dropStartDt 不回复的启动激活帧数量,startDts为收到的启动激活帧数量
+	dropStartDt int
+	startDts    int
+	stopDts     int //收到的停止激活帧数量
+}
+
+//NewTestServer 在address上监听,如"127.0.0.1:0"
+func NewTestServer(address string) (*TestServer, error) {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	s := &TestServer{ln: ln, frames: make(chan []byte, 1024)}
+	go s.serve()
+	return s, nil
+}
+
+//Addr 返回监听地址
+func (s *TestServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+//Close 停止监听并断开当前连接
+func (s *TestServer) Close() error {
+	err := s.ln.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	return err
+}
+
+//Frames 返回收到的帧,不包含启动激活帧和停止激活帧,未及时取出时最多缓存1024帧
+func (s *TestServer) Frames() <-chan []byte {
+	return s.frames
+}
+
+func (s *TestServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conn = conn
+		s.ssn, s.rsn = 0, 0
+		s.mu.Unlock()
+		go s.read(conn)
+	}
+}
+
+func (s *TestServer) read(conn net.Conn) {
+	for {
+		frame, err := ReadFrame(conn)
+		if err != nil {
+			return
+		}
+		if bytes.Equal(frame[2:6], stopDtAct[:]) {
+			s.mu.Lock()
+			s.stopDts++
+			s.mu.Unlock()
+			conn.Write(convertBytes(stopDtCon[:]))
+			continue
+		}
+		if bytes.Equal(frame[2:6], startDtAct[:]) {
+			s.mu.Lock()
+			//启动数据传输时序号从0开始
+			s.ssn, s.rsn = 0, 0
+			s.startDts++
+			drop := s.startDts <= s.dropStartDt
+			s.mu.Unlock()
+			if !drop {
+				conn.Write(convertBytes(startDtCon[:]))
+			}
+			continue
+		}
+		if frame[2]&1 == iFrame {
+			s.mu.Lock()
+			s.rsn++
+			if s.autoAck && !s.skipAck {
+				conn.Write(encodeSFrame(s.rsn))
+			}
+			s.skipAck = false
+			s.mu.Unlock()
+		}
+		s.frames <- frame
+	}
+}
+
+//SetAutoAck 设置是否每收到一个I帧立即回复S帧,默认不回复,由SendIFrame捎带或SendSFrame确认
+func (s *TestServer) SetAutoAck(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.autoAck = on
+}
+
+//SendIFrame 以I帧发送asdu,接收序号为已收到的I帧数量
+func (s *TestServer) SendIFrame(asdu []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return ErrNotConnected
+	}
+	_, err := s.conn.Write(encodeIFrame(s.ssn, s.rsn, asdu))
+	s.ssn++
+	return err
+}
+
+//SendSFrame 发送接收序号为已收到的I帧数量的S帧
+func (s *TestServer) SendSFrame() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return ErrNotConnected
+	}
+	_, err := s.conn.Write(encodeSFrame(s.rsn))
+	return err
+}
+
+//InjectBadLength 发送长度字节超过最大值的非法帧,用于验证主站丢弃字节后重新同步
+func (s *TestServer) InjectBadLength() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return ErrNotConnected
+	}
+	_, err := s.conn.Write([]byte{startFrame, 0xFF, 0x00, 0x00, 0x00, 0x00})
+	return err
+}
+
+//SkipNextAck 收到下一个I帧时不自动回复S帧,之后的S帧和I帧仍按已收到的I帧数量确认,用于验证主站的k窗口和t1超时
+func (s *TestServer) SkipNextAck() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skipAck = true
+}
+
+//SendDuplicateSSN 下一个I帧重复使用上一个I帧的发送序号,用于验证主站的序号校验
+func (s *TestServer) SendDuplicateSSN() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ssn--
+}
@@ -0,0 +1,56 @@
+package iec104
+
+import (
+	"testing"
+	"time"
+)
+
+//waitUnacknowledged 等待客户端已发送未被确认的I帧数量变为n
+func waitUnacknowledged(t *testing.T, c *Client, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Health().Unacknowledged != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Client.Health().Unacknowledged = %d, want %d", c.Health().Unacknowledged, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTestServer_SkipNextAck(t *testing.T) {
+	s := newTestServer(t)
+	s.SetAutoAck(true)
+	c := newTestClient(t, s, Config{})
+	waitUnacknowledged(t, c, 0)
+	s.SkipNextAck()
+	go c.SendSingleCommand(1, 0x6001, true, false)
+	s.nextIFrame(t, CScNa1)
+	//跳过确认后该I帧保持未确认
+	time.Sleep(50 * time.Millisecond)
+	waitUnacknowledged(t, c, 1)
+	//下一个I帧的S帧同时确认之前的I帧
+	go c.SendSingleCommand(1, 0x6002, true, false)
+	s.nextIFrame(t, CScNa1)
+	waitUnacknowledged(t, c, 0)
+}
+
+func TestTestServer_SkipNextAckSendWindow(t *testing.T) {
+	s := newTestServer(t)
+	s.SetAutoAck(true)
+	c := newTestClient(t, s, Config{SendWindow: 1})
+	waitUnacknowledged(t, c, 0)
+	s.SkipNextAck()
+	go c.SendSingleCommand(1, 0x6001, true, false)
+	s.nextIFrame(t, CScNa1)
+	go c.SendSingleCommand(1, 0x6002, true, false)
+	//k窗口已满,未确认前不发送下一个I帧
+	select {
+	case frame := <-s.Frames():
+		t.Fatalf("k窗口已满时发送了帧 [% X]", frame)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := s.SendSFrame(); err != nil {
+		t.Fatalf("TestServer.SendSFrame() error = %v", err)
+	}
+	s.nextIFrame(t, CScNa1)
+}
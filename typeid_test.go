@@ -0,0 +1,29 @@
+package iec104
+
+import "testing"
+
+func TestTypeIDName(t *testing.T) {
+	tests := []struct {
+		name string
+		t    byte
+		want string
+	}{
+		{"测试单点命令", 45, "C_SC_NA_1"},
+		{"测试短浮点遥测", 13, "M_ME_NC_1"},
+		{"测试文件传输", 127, "F_SC_NB_1"},
+		{"测试保留类型", 22, "TypeID(22)"},
+		{"测试私有类型", 128, "TypeID(128)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TypeIDName(tt.t); got != tt.want {
+				t.Errorf("TypeIDName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	for _, info := range SupportedTypeIDs() {
+		if got := TypeIDName(info.TypeID); got != info.Name {
+			t.Errorf("TypeIDName(%d) = %v, want %v", info.TypeID, got, info.Name)
+		}
+	}
+}
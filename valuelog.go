@@ -0,0 +1,46 @@
+package iec104
+
+import "time"
+
+//valueLog 限制每秒打印的数据日志条数,只在读协程中访问
+type valueLog struct {
+	start   time.Time //当前1秒窗口的开始时间
+	count   int       //当前窗口已打印的条数
+	dropped int       //当前窗口因限流未打印的条数
+}
+
+//logValues 按Config.LogValues以info级别打印解析后的每个信息对象
+func (c *Client) logValues(apdu *APDU) {
+	if !c.Config.LogValues {
+		return
+	}
+	now := c.clock().Now()
+	for _, s := range apdu.Signals {
+		if !c.allowValueLog(now) {
+			continue
+		}
+		c.Logger.Infof("公共地址:%d,类型:%s,信息对象地址:%d,值:%v,品质:0x%02X,传输原因:%d",
+			apdu.ASDU.PublicAddress, TypeIDName(byte(s.TypeID)), s.Address, s.Value, s.Quality, s.Cause)
+	}
+}
+
+//allowValueLog 是否还能在当前窗口打印,超过Config.LogValuesPerSecond时丢弃,
+//下一个窗口开始时打印上一窗口丢弃的条数
+func (c *Client) allowValueLog(now time.Time) bool {
+	limit := c.Config.LogValuesPerSecond
+	if limit <= 0 {
+		return true
+	}
+	if now.Sub(c.valueLog.start) >= time.Second {
+		if c.valueLog.dropped > 0 {
+			c.Logger.Infof("限流未打印%d条数据日志", c.valueLog.dropped)
+		}
+		c.valueLog = valueLog{start: now}
+	}
+	if c.valueLog.count >= limit {
+		c.valueLog.dropped++
+		return false
+	}
+	c.valueLog.count++
+	return true
+}